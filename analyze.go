@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/serrhiy/go-huffman/huffman"
+	"github.com/serrhiy/go-huffman/internal/symbols"
+)
+
+func writeAnalysisText(out io.Writer, analysis *huffman.Analysis) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "size:\t%d bytes\n", analysis.Size)
+	fmt.Fprintf(writer, "symbols:\t%d\n", len(analysis.Symbols))
+	fmt.Fprintf(writer, "entropy:\t%.4f bits/symbol\n", analysis.Entropy)
+	fmt.Fprintf(writer, "average code length:\t%.4f bits/symbol\n", analysis.AverageCodeLength)
+	fmt.Fprintf(writer, "redundancy:\t%.4f bits/symbol\n", analysis.Redundancy)
+	fmt.Fprintf(writer, "theoretical size:\t%d bytes\n", analysis.TheoreticalSize)
+	fmt.Fprintf(writer, "encoded size:\t%d bytes (tree %d bits, content %d bits)\n",
+		analysis.EncodedSize, analysis.TreeSize, analysis.ContentSize)
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if len(analysis.Symbols) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(out)
+	writer = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "symbol\tcount\tprobability\tlength\tcode")
+	for _, symbol := range analysis.Symbols {
+		fmt.Fprintf(writer, "%s\t%d\t%.6f\t%d\t%s\n",
+			symbols.Format(symbol.Symbol), symbol.Count, symbol.Probability, symbol.CodeLength, symbol.Code)
+	}
+	return writer.Flush()
+}
+
+func writeAnalysisCSV(out io.Writer, analysis *huffman.Analysis) error {
+	writer := csv.NewWriter(out)
+	writer.Write([]string{"symbol", "count", "probability", "code_length", "code"})
+	for _, symbol := range analysis.Symbols {
+		writer.Write([]string{
+			strconv.Itoa(int(symbol.Symbol)),
+			strconv.FormatUint(uint64(symbol.Count), 10),
+			strconv.FormatFloat(symbol.Probability, 'g', -1, 64),
+			strconv.Itoa(symbol.CodeLength),
+			symbol.Code,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeAnalysisJSON(out io.Writer, analysis *huffman.Analysis) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(analysis)
+}
+
+func runAnalyze(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	format := flags.String("format", "text", "output format: text, csv or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("exactly one input file must be specified")
+	}
+	writers := map[string]func(io.Writer, *huffman.Analysis) error{
+		"text": writeAnalysisText,
+		"csv":  writeAnalysisCSV,
+		"json": writeAnalysisJSON,
+	}
+	write, ok := writers[*format]
+	if !ok {
+		return fmt.Errorf("unknown output format: %q", *format)
+	}
+
+	infile, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer infile.Close()
+	header, err := getHeader(infile)
+	if err != nil {
+		return err
+	}
+	analysis, err := huffman.AnalyzeWithHeader(infile, header)
+	if err != nil {
+		return err
+	}
+	return write(out, analysis)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/serrhiy/go-huffman/huffman"
+)
+
+func TestRunAnalyze(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.txt")
+	os.WriteFile(path, []byte("abracadabra"), 0644)
+
+	t.Run("text", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		if err := runAnalyze([]string{path}, out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, expected := range []string{"entropy:", "redundancy:", "encoded size:", "'a'"} {
+			if !strings.Contains(out.String(), expected) {
+				t.Fatalf("expected %q in output:\n%s", expected, out.String())
+			}
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		if err := runAnalyze([]string{"-format", "csv", path}, out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		records, err := csv.NewReader(out).ReadAll()
+		if err != nil {
+			t.Fatalf("invalid csv: %v", err)
+		}
+		if len(records) != 6 || records[1][0] != "97" || records[1][1] != "5" {
+			t.Fatalf("unexpected records: %v", records)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		if err := runAnalyze([]string{"-format", "json", path}, out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var analysis huffman.Analysis
+		if err := json.Unmarshal(out.Bytes(), &analysis); err != nil {
+			t.Fatalf("invalid json: %v", err)
+		}
+		if analysis.Size != 11 || len(analysis.Symbols) != 5 {
+			t.Fatalf("unexpected analysis: %+v", analysis)
+		}
+	})
+
+	t.Run("encoded size of the command line output", func(t *testing.T) {
+		dir := t.TempDir()
+		input := filepath.Join(dir, "input.txt")
+		os.WriteFile(input, []byte(strings.Repeat("abracadabra", 500)), 0644)
+		out := &bytes.Buffer{}
+		if err := runAnalyze([]string{"-format", "json", input}, out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var analysis huffman.Analysis
+		if err := json.Unmarshal(out.Bytes(), &analysis); err != nil {
+			t.Fatalf("invalid json: %v", err)
+		}
+		withFlags(t, input, "", "", false)
+		if err := start(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		info, err := os.Stat(filepath.Join(dir, "input.hfm"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if analysis.EncodedSize != uint64(info.Size()) {
+			t.Fatalf("invalid encoded size, expected: %d, got: %d", info.Size(), analysis.EncodedSize)
+		}
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		if err := runAnalyze(nil, &bytes.Buffer{}); err == nil {
+			t.Fatal("expected error without input file")
+		}
+		if err := runAnalyze([]string{"-format", "xml", path}, &bytes.Buffer{}); err == nil {
+			t.Fatal("expected error for unknown format")
+		}
+		if err := runAnalyze([]string{filepath.Join(t.TempDir(), "missing")}, &bytes.Buffer{}); err == nil {
+			t.Fatal("expected error for missing file")
+		}
+	})
+}
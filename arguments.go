@@ -3,6 +3,8 @@ package main
 import (
 	"errors"
 	"path/filepath"
+
+	"github.com/serrhiy/go-huffman/huffman"
 )
 
 type arguments struct {
@@ -19,7 +21,7 @@ func getArgumentsEncode(input, output string) (*arguments, error) {
 		ext := filepath.Ext(base)
 		name := base[:len(base)-len(ext)]
 		dir := filepath.Dir(input)
-		otuputPath := filepath.Join(dir, name+OutputExtension)
+		otuputPath := filepath.Join(dir, name+outputExtension())
 		return &arguments{input, otuputPath}, nil
 	}
 	return &arguments{input, output}, nil
@@ -29,12 +31,26 @@ func getArgumentsDecode(input, output string) (*arguments, error) {
 	if input == "" {
 		return nil, errors.New("input argument is mandatory")
 	}
-	if output == "" {
-		return nil, errors.New("output argument is mandatory")
-	}
 	return &arguments{input, output}, nil
 }
 
+func getDecodeOutput(input string, header *huffman.Header) (string, error) {
+	dir := filepath.Dir(input)
+	if header != nil && header.Name != "" {
+		name := filepath.Base(header.Name)
+		if name == "." || name == ".." || name == string(filepath.Separator) {
+			return "", errors.New("invalid file name stored in header, specify output")
+		}
+		return filepath.Join(dir, name), nil
+	}
+	base := filepath.Base(input)
+	ext := filepath.Ext(base)
+	if ext != outputExtension() || len(base) == len(ext) {
+		return "", errors.New("output argument is mandatory")
+	}
+	return filepath.Join(dir, base[:len(base)-len(ext)]), nil
+}
+
 func getArguments(encode, decode, output string) (*arguments, error) {
 	if len(encode) > 0 && len(decode) > 0 {
 		return nil, errors.New("both encode and decode paths are set, specify only one")
@@ -2,6 +2,8 @@ package main
 
 import (
 	"testing"
+
+	"github.com/serrhiy/go-huffman/huffman"
 )
 
 func TestGetArgumentsEncode(t *testing.T) {
@@ -73,8 +75,12 @@ func TestGetArgumentsDecode(t *testing.T) {
 	})
 
 	t.Run("empty output", func(t *testing.T) {
-		if _, err := getArgumentsDecode(input, ""); err == nil {
-			t.Fatal("expected error for empty output")
+		args, err := getArgumentsDecode(input, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if args.outputFile != "" {
+			t.Fatalf("expected output to be resolved later, got '%s'", args.outputFile)
 		}
 	})
 
@@ -93,6 +99,66 @@ func TestGetArgumentsDecode(t *testing.T) {
 	})
 }
 
+func TestGetDecodeOutput(t *testing.T) {
+	t.Run("name from header", func(t *testing.T) {
+		output, err := getDecodeOutput("/tmp/input.hfm", &huffman.Header{Name: "original.txt"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if output != "/tmp/original.txt" {
+			t.Fatalf("expected %q, got %q", "/tmp/original.txt", output)
+		}
+	})
+
+	t.Run("name with directories", func(t *testing.T) {
+		output, err := getDecodeOutput("/tmp/input.hfm", &huffman.Header{Name: "../../etc/passwd"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if output != "/tmp/passwd" {
+			t.Fatalf("expected %q, got %q", "/tmp/passwd", output)
+		}
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		if _, err := getDecodeOutput("/tmp/input.hfm", &huffman.Header{Name: ".."}); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	})
+
+	t.Run("no header", func(t *testing.T) {
+		output, err := getDecodeOutput("/tmp/input.hfm", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if output != "/tmp/input" {
+			t.Fatalf("expected %q, got %q", "/tmp/input", output)
+		}
+	})
+
+	t.Run("header without name", func(t *testing.T) {
+		output, err := getDecodeOutput("/tmp/input.hfm", &huffman.Header{Mode: 0644})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if output != "/tmp/input" {
+			t.Fatalf("expected %q, got %q", "/tmp/input", output)
+		}
+	})
+
+	t.Run("unknown extension", func(t *testing.T) {
+		if _, err := getDecodeOutput("/tmp/input.huff", nil); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	})
+
+	t.Run("extension only", func(t *testing.T) {
+		if _, err := getDecodeOutput("/tmp/.hfm", nil); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	})
+}
+
 func TestGetArguments(t *testing.T) {
 	t.Run("both encode and decode set", func(t *testing.T) {
 		_, err := getArguments("in.txt", "in.hfm", "")
@@ -128,3 +194,36 @@ func TestGetArguments(t *testing.T) {
 		}
 	})
 }
+
+func TestGetLevel(t *testing.T) {
+	set := func(t *testing.T, values ...int) {
+		for _, level := range values {
+			*levels[level] = true
+		}
+		t.Cleanup(func() {
+			for _, level := range values {
+				*levels[level] = false
+			}
+		})
+	}
+
+	t.Run("default", func(t *testing.T) {
+		if level, err := getLevel(); err != nil || level != 0 {
+			t.Fatalf("expected level 0, got: %d, err: %v", level, err)
+		}
+	})
+
+	t.Run("single", func(t *testing.T) {
+		set(t, 7)
+		if level, err := getLevel(); err != nil || level != 7 {
+			t.Fatalf("expected level 7, got: %d, err: %v", level, err)
+		}
+	})
+
+	t.Run("several", func(t *testing.T) {
+		set(t, 1, 9)
+		if _, err := getLevel(); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	})
+}
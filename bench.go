@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	"github.com/serrhiy/go-huffman/benchkit"
+	"github.com/serrhiy/go-huffman/huffman"
+)
+
+const benchSize = 1 << 20
+
+// benchMode encodes and decodes an input. The frequencies of the input are
+// passed to both sides for the modes that take a precomputed table.
+type benchMode struct {
+	name   string
+	encode func(in io.ReadSeeker, out io.Writer, frequencies map[byte]uint) error
+	decode func(in io.Reader, out io.Writer, frequencies map[byte]uint) error
+}
+
+var benchModes = []benchMode{
+	{
+		name: "plain",
+		encode: func(in io.ReadSeeker, out io.Writer, _ map[byte]uint) error {
+			return huffman.NewEncoder(in, out).Encode()
+		},
+		decode: func(in io.Reader, out io.Writer, _ map[byte]uint) error {
+			return huffman.NewDecoder(in, out).Decode()
+		},
+	},
+	{
+		name: "two-queue",
+		encode: func(in io.ReadSeeker, out io.Writer, _ map[byte]uint) error {
+			options := huffman.EncoderOptions{TreeBuilder: huffman.TwoQueueTreeBuilder}
+			return huffman.NewEncoderWithOptions(in, out, options).Encode()
+		},
+		decode: func(in io.Reader, out io.Writer, _ map[byte]uint) error {
+			return huffman.NewDecoder(in, out).Decode()
+		},
+	},
+	{
+		name: "in-place",
+		encode: func(in io.ReadSeeker, out io.Writer, _ map[byte]uint) error {
+			options := huffman.EncoderOptions{TreeBuilder: huffman.InPlaceTreeBuilder}
+			return huffman.NewEncoderWithOptions(in, out, options).Encode()
+		},
+		decode: func(in io.Reader, out io.Writer, _ map[byte]uint) error {
+			return huffman.NewDecoder(in, out).Decode()
+		},
+	},
+	{
+		name: "static",
+		encode: func(in io.ReadSeeker, out io.Writer, frequencies map[byte]uint) error {
+			options := huffman.EncoderOptions{Frequencies: frequencies}
+			return huffman.NewEncoderWithOptions(in, out, options).Encode()
+		},
+		decode: func(in io.Reader, out io.Writer, _ map[byte]uint) error {
+			return huffman.NewDecoder(in, out).Decode()
+		},
+	},
+	{
+		name: "shared",
+		encode: func(in io.ReadSeeker, out io.Writer, frequencies map[byte]uint) error {
+			options := huffman.EncoderOptions{Frequencies: frequencies, SharedTable: true}
+			return huffman.NewEncoderWithOptions(in, out, options).Encode()
+		},
+		decode: func(in io.Reader, out io.Writer, frequencies map[byte]uint) error {
+			options := huffman.DecoderOptions{Frequencies: frequencies}
+			return huffman.NewDecoderWithOptions(in, out, options).Decode()
+		},
+	},
+	{
+		name: "deflate",
+		encode: func(in io.ReadSeeker, out io.Writer, _ map[byte]uint) error {
+			options := huffman.EncoderOptions{RawDeflate: true}
+			return huffman.NewEncoderWithOptions(in, out, options).Encode()
+		},
+		decode: func(in io.Reader, out io.Writer, _ map[byte]uint) error {
+			options := huffman.DecoderOptions{RawDeflate: true}
+			return huffman.NewDecoderWithOptions(in, out, options).Decode()
+		},
+	},
+	{
+		name: "split",
+		encode: func(in io.ReadSeeker, out io.Writer, _ map[byte]uint) error {
+			options := huffman.EncoderOptions{SplitBlocks: true}
+			return huffman.NewEncoderWithOptions(in, out, options).Encode()
+		},
+		decode: func(in io.Reader, out io.Writer, _ map[byte]uint) error {
+			return huffman.NewDecoder(in, out).Decode()
+		},
+	},
+	{
+		name: "lz77",
+		encode: func(in io.ReadSeeker, out io.Writer, _ map[byte]uint) error {
+			options := huffman.EncoderOptions{LZ77: &huffman.LZ77Options{}}
+			return huffman.NewEncoderWithOptions(in, out, options).Encode()
+		},
+		decode: func(in io.Reader, out io.Writer, _ map[byte]uint) error {
+			return huffman.NewDecoder(in, out).Decode()
+		},
+	},
+}
+
+func init() {
+	for level := 1; level <= 9; level++ {
+		benchModes = append(benchModes, benchMode{
+			name: fmt.Sprintf("level-%d", level),
+			encode: func(in io.ReadSeeker, out io.Writer, _ map[byte]uint) error {
+				options := huffman.EncoderOptions{Level: level}
+				return huffman.NewEncoderWithOptions(in, out, options).Encode()
+			},
+			decode: func(in io.Reader, out io.Writer, _ map[byte]uint) error {
+				return huffman.NewDecoder(in, out).Decode()
+			},
+		})
+	}
+}
+
+type benchInput struct {
+	name string
+	data []byte
+}
+
+type benchResult struct {
+	input       string
+	mode        string
+	size        int
+	encodedSize int
+	encodeTime  time.Duration
+	decodeTime  time.Duration
+	memory      uint64
+}
+
+func (result benchResult) ratio() float64 {
+	if result.size == 0 {
+		return 0
+	}
+	return float64(result.encodedSize) / float64(result.size) * 100
+}
+
+func getBenchInputs(paths []string) ([]benchInput, error) {
+	if len(paths) == 0 {
+		return []benchInput{
+			{"text", []byte(benchkit.Text(benchSize))},
+			{"random", []byte(benchkit.Random(benchSize))},
+			{"repeating", bytes.Repeat([]byte{'a'}, benchSize)},
+			{"range", bytes.Repeat([]byte(benchkit.Range(0, 256)), benchSize/256)},
+			{"mixed", mixedInput(benchSize)},
+		}, nil
+	}
+	inputs := make([]benchInput, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, benchInput{filepath.Base(path), data})
+	}
+	return inputs, nil
+}
+
+// mixedInput returns random bytes in which every other section of 96 KiB
+// uses only 16 byte values, so the statistics change within blocks.
+func mixedInput(size int) []byte {
+	data := []byte(benchkit.Random(size))
+	for i := range data {
+		if i/(96<<10)%2 == 1 {
+			data[i] &= 0x0f
+		}
+	}
+	return data
+}
+
+func allocated() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.TotalAlloc
+}
+
+// frequencies counts the bytes of data. The static and shared modes code the
+// input with this table as if it had been trained on similar data beforehand.
+func frequencies(data []byte) map[byte]uint {
+	var counts [256]uint64
+	for _, b := range data {
+		counts[b]++
+	}
+	return huffman.FrequencyMap(&counts)
+}
+
+func benchmark(input benchInput, mode benchMode, iterations int) (benchResult, error) {
+	result := benchResult{input: input.name, mode: mode.name, size: len(input.data)}
+	table := frequencies(input.data)
+	encoded := &bytes.Buffer{}
+	decoded := &bytes.Buffer{}
+	before := allocated()
+	for i := range iterations {
+		encoded.Reset()
+		start := time.Now()
+		if err := mode.encode(bytes.NewReader(input.data), encoded, table); err != nil {
+			return result, err
+		}
+		encodeTime := time.Since(start)
+
+		decoded.Reset()
+		start = time.Now()
+		if err := mode.decode(bytes.NewReader(encoded.Bytes()), decoded, table); err != nil {
+			return result, err
+		}
+		decodeTime := time.Since(start)
+
+		if !bytes.Equal(decoded.Bytes(), input.data) {
+			return result, errors.New("decoded data does not match the input")
+		}
+		if i == 0 || encodeTime < result.encodeTime {
+			result.encodeTime = encodeTime
+		}
+		if i == 0 || decodeTime < result.decodeTime {
+			result.decodeTime = decodeTime
+		}
+	}
+	result.encodedSize = encoded.Len()
+	result.memory = (allocated() - before) / uint64(iterations)
+	return result, nil
+}
+
+func writeBenchTable(out io.Writer, results []benchResult) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(writer, "input\tmode\tsize\tencoded\tratio\tencode MB/s\tdecode MB/s\tmemory/op\t")
+	for _, result := range results {
+		fmt.Fprintf(
+			writer, "%s\t%s\t%s\t%s\t%.2f%%\t%.1f\t%.1f\t%s\t\n",
+			result.input, result.mode, formatBytes(int64(result.size)),
+			formatBytes(int64(result.encodedSize)), result.ratio(),
+			throughput(int64(result.size), result.encodeTime),
+			throughput(int64(result.size), result.decodeTime),
+			formatBytes(int64(result.memory)),
+		)
+	}
+	return writer.Flush()
+}
+
+func runBench(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	iterations := flags.Int("count", 3, "number of round-trips per input and mode")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *iterations < 1 {
+		return errors.New("number of round-trips must be positive")
+	}
+	inputs, err := getBenchInputs(flags.Args())
+	if err != nil {
+		return err
+	}
+	results := make([]benchResult, 0, len(inputs)*len(benchModes))
+	for _, input := range inputs {
+		for _, mode := range benchModes {
+			result, err := benchmark(input, mode, *iterations)
+			if err != nil {
+				return fmt.Errorf("%s (%s): %w", input.name, mode.name, err)
+			}
+			results = append(results, result)
+		}
+	}
+	return writeBenchTable(out, results)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetBenchInputs(t *testing.T) {
+	t.Run("generated", func(t *testing.T) {
+		inputs, err := getBenchInputs(nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(inputs) == 0 {
+			t.Fatal("expected generated inputs")
+		}
+		for _, input := range inputs {
+			if len(input.data) != benchSize {
+				t.Fatalf("%s: expected %d bytes, got: %d", input.name, benchSize, len(input.data))
+			}
+		}
+	})
+
+	t.Run("files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "corpus.txt")
+		os.WriteFile(path, []byte("corpus"), 0644)
+		inputs, err := getBenchInputs([]string{path})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(inputs) != 1 || inputs[0].name != "corpus.txt" || string(inputs[0].data) != "corpus" {
+			t.Fatalf("invalid inputs: %+v", inputs)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := getBenchInputs([]string{filepath.Join(t.TempDir(), "missing")}); err == nil {
+			t.Fatal("expected error for missing file")
+		}
+	})
+}
+
+func TestBenchmark(t *testing.T) {
+	input := benchInput{"text", []byte(strings.Repeat("hello world ", 100))}
+	for _, mode := range benchModes {
+		t.Run(mode.name, func(t *testing.T) {
+			result, err := benchmark(input, mode, 2)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.size != len(input.data) || result.encodedSize == 0 {
+				t.Fatalf("invalid sizes: %+v", result)
+			}
+			if result.encodeTime <= 0 || result.decodeTime <= 0 {
+				t.Fatalf("invalid timings: %+v", result)
+			}
+		})
+	}
+
+	t.Run("round-trip mismatch", func(t *testing.T) {
+		mode := benchModes[0]
+		mode.decode = func(in io.Reader, out io.Writer, _ map[byte]uint) error {
+			_, err := out.Write([]byte("other"))
+			return err
+		}
+		if _, err := benchmark(input, mode, 1); err == nil {
+			t.Fatal("expected error for mismatched output")
+		}
+	})
+
+	t.Run("encode error", func(t *testing.T) {
+		mode := benchModes[0]
+		mode.encode = func(io.ReadSeeker, io.Writer, map[byte]uint) error {
+			return errors.New("encode failed")
+		}
+		if _, err := benchmark(input, mode, 1); err == nil {
+			t.Fatal("expected error from encoder")
+		}
+	})
+}
+
+func TestRunBench(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corpus.txt")
+	os.WriteFile(path, []byte(strings.Repeat("abracadabra", 1000)), 0644)
+	out := &bytes.Buffer{}
+	if err := runBench([]string{"-count", "1", path}, out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1+len(benchModes) {
+		t.Fatalf("expected header and %d rows, got:\n%s", len(benchModes), out.String())
+	}
+	if !strings.Contains(lines[0], "ratio") || !strings.Contains(lines[1], "corpus.txt") {
+		t.Fatalf("unexpected table:\n%s", out.String())
+	}
+
+	if err := runBench([]string{"-count", "0"}, out); err == nil {
+		t.Fatal("expected error for non-positive round-trips")
+	}
+}
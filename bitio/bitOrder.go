@@ -0,0 +1,14 @@
+package bitio
+
+// BitOrder tells in which order bits are packed into bytes.
+type BitOrder byte
+
+const (
+	// MSBFirst packs bits starting from the most significant bit of every
+	// byte, and multi-bit values starting from their most significant bit.
+	MSBFirst BitOrder = iota
+	// LSBFirst packs bits starting from the least significant bit of every
+	// byte, and multi-bit values starting from their least significant bit,
+	// as DEFLATE, GIF and Brotli do.
+	LSBFirst
+)
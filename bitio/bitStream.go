@@ -1,22 +1,55 @@
 package bitio
 
 import (
+	"fmt"
+	"io"
 	"iter"
+	"math/bits"
+	"strings"
 )
 
+// BitStream is a sequence of bits packed into 64-bit words in the given bit
+// order. Bits past the end of the stream are always zero.
 type BitStream struct {
 	bits     []uint64
 	position uint8
+	order    BitOrder
 }
 
 func NewBitStream() *BitStream {
-	return &BitStream{[]uint64{0}, 0}
+	return NewBitStreamWithOrder(MSBFirst)
+}
+
+func NewBitStreamWithOrder(order BitOrder) *BitStream {
+	return &BitStream{[]uint64{0}, 0, order}
+}
+
+// BitStreamFromBytes returns the bits of data packed in order, without the
+// last padding bits of the last byte.
+func BitStreamFromBytes(data []byte, padding byte, order BitOrder) (*BitStream, error) {
+	if padding > 7 || len(data) == 0 && padding > 0 {
+		return nil, fmt.Errorf("invalid padding: %d", padding)
+	}
+	stream := NewBitStreamWithOrder(order)
+	for _, b := range data {
+		stream.Append(uint64(b), 8)
+	}
+	stream.truncate(stream.Len() - int(padding))
+	return stream, nil
 }
 
 func BitStreamCopy(stream *BitStream) *BitStream {
 	bits := make([]uint64, len(stream.bits))
 	copy(bits, stream.bits)
-	return &BitStream{bits, stream.position}
+	return &BitStream{bits, stream.position, stream.order}
+}
+
+// shift returns the shift of the bit at index of a word.
+func (stream *BitStream) shift(index uint8) uint8 {
+	if stream.order == LSBFirst {
+		return index
+	}
+	return 63 - index
 }
 
 func (stream *BitStream) Len() int {
@@ -27,7 +60,7 @@ func (stream *BitStream) Push(bit byte) {
 	bit &= 1
 
 	index := len(stream.bits) - 1
-	stream.bits[index] |= uint64(bit) << (63 - uint64(stream.position))
+	stream.bits[index] |= uint64(bit) << stream.shift(stream.position)
 	stream.position += 1
 	if stream.position >= 64 {
 		stream.bits = append(stream.bits, 0)
@@ -44,7 +77,7 @@ func (stream *BitStream) Iter() iter.Seq[byte] {
 			}
 
 			for j := byte(0); j < stop; j++ {
-				bit := (bitset >> (63 - j)) & 1
+				bit := (bitset >> stream.shift(j)) & 1
 				if !yield(byte(bit)) {
 					return
 				}
@@ -52,3 +85,200 @@ func (stream *BitStream) Iter() iter.Seq[byte] {
 		}
 	}
 }
+
+func (stream *BitStream) checkIndex(index int) {
+	if index < 0 || index >= stream.Len() {
+		panic(fmt.Sprintf("bitio: index out of range [%d] with length %d", index, stream.Len()))
+	}
+}
+
+// Get returns the bit at index.
+func (stream *BitStream) Get(index int) byte {
+	stream.checkIndex(index)
+	return byte(stream.bits[index/64] >> stream.shift(uint8(index%64)) & 1)
+}
+
+// Set replaces the bit at index.
+func (stream *BitStream) Set(index int, bit byte) {
+	stream.checkIndex(index)
+	mask := uint64(1) << stream.shift(uint8(index%64))
+	if bit&1 == 1 {
+		stream.bits[index/64] |= mask
+	} else {
+		stream.bits[index/64] &^= mask
+	}
+}
+
+// Append adds the n lowest bits of bits, n <= 64, starting from bit n-1, or
+// from bit 0 with LSBFirst.
+func (stream *BitStream) Append(bits uint64, n byte) {
+	if n > 64 {
+		panic(fmt.Sprintf("bitio: invalid number of bits: %d", n))
+	}
+	if n < 64 {
+		bits &= 1<<n - 1
+	}
+	for n > 0 {
+		index := len(stream.bits) - 1
+		free := 64 - stream.position
+		size := min(n, free)
+		if stream.order == LSBFirst {
+			stream.bits[index] |= bits << stream.position
+			bits >>= size
+		} else {
+			stream.bits[index] |= bits >> (n - size) << (free - size)
+			bits &= 1<<(n-size) - 1
+		}
+		n -= size
+		stream.position += size
+		if stream.position == 64 {
+			stream.bits = append(stream.bits, 0)
+			stream.position = 0
+		}
+	}
+}
+
+// word returns the bits of the word at index as Append takes them, and
+// their number.
+func (stream *BitStream) word(index int) (uint64, byte) {
+	word, n := stream.bits[index], byte(64)
+	if index == len(stream.bits)-1 {
+		n = stream.position
+	}
+	if stream.order == MSBFirst && n > 0 {
+		word >>= 64 - n
+	}
+	return word, n
+}
+
+// AppendStream adds all bits of other.
+func (stream *BitStream) AppendStream(other *BitStream) {
+	if other == stream {
+		other = BitStreamCopy(other)
+	}
+	if other.order != stream.order {
+		for bit := range other.Iter() {
+			stream.Push(bit)
+		}
+		return
+	}
+	for index := range other.bits {
+		stream.Append(other.word(index))
+	}
+}
+
+// Slice returns a new stream with the bits from start up to, but not
+// including, end.
+func (stream *BitStream) Slice(start, end int) *BitStream {
+	if start < 0 || end < start || end > stream.Len() {
+		panic(fmt.Sprintf("bitio: slice bounds out of range [%d:%d] with length %d", start, end, stream.Len()))
+	}
+	result := NewBitStreamWithOrder(stream.order)
+	for index := start; index < end; index++ {
+		result.Push(stream.Get(index))
+	}
+	return result
+}
+
+// truncate drops the bits from length on.
+func (stream *BitStream) truncate(length int) {
+	stream.bits = stream.bits[:length/64+1]
+	stream.position = uint8(length % 64)
+	keep := ^uint64(0) << (64 - stream.position)
+	if stream.order == LSBFirst {
+		keep = 1<<stream.position - 1
+	}
+	stream.bits[len(stream.bits)-1] &= keep
+}
+
+// Bytes packs the bits into bytes in the order of the stream and returns
+// them with the number of zero bits padding the last byte.
+func (stream *BitStream) Bytes() ([]byte, byte) {
+	length := stream.Len()
+	result := make([]byte, (length+7)/8)
+	for index := range result {
+		word, shift := stream.bits[index/8], uint(index%8)*8
+		if stream.order == LSBFirst {
+			result[index] = byte(word >> shift)
+		} else {
+			result[index] = byte(word >> (56 - shift))
+		}
+	}
+	return result, byte((8 - length%8) % 8)
+}
+
+// WriteTo writes the bits to w. A *Writer receives them as they are, in its
+// own bit order, without padding; any other writer receives the bytes
+// returned by Bytes. The result is the number of bytes produced: for a
+// *Writer, the bytes completed by the bits together with those it already
+// held, while the remaining bits stay in the writer.
+func (stream *BitStream) WriteTo(w io.Writer) (int64, error) {
+	writer, ok := w.(*Writer)
+	if !ok {
+		data, _ := stream.Bytes()
+		written, err := w.Write(data)
+		return int64(written), err
+	}
+	written := int64(writer.cacheSize)
+	for index := range stream.bits {
+		word, n := stream.word(index)
+		if n == 0 {
+			continue
+		}
+		if writer.order != stream.order {
+			// the first bit is the most significant one with MSBFirst and
+			// the least significant one with LSBFirst
+			word = bits.Reverse64(word) >> (64 - n)
+		}
+		if err := writer.WriteBitsUint64(word, n); err != nil {
+			return written / 8, err
+		}
+		written += int64(n)
+	}
+	return written / 8, nil
+}
+
+// Compare compares the bits of two streams lexicographically, like
+// strings.Compare does with their String forms.
+func (stream *BitStream) Compare(other *BitStream) int {
+	length := min(stream.Len(), other.Len())
+	for index := range length {
+		if a, b := stream.Get(index), other.Get(index); a != b {
+			return int(a) - int(b)
+		}
+	}
+	switch {
+	case stream.Len() < other.Len():
+		return -1
+	case stream.Len() > other.Len():
+		return 1
+	}
+	return 0
+}
+
+// Equal reports whether both streams hold the same bits, whatever their bit
+// order.
+func (stream *BitStream) Equal(other *BitStream) bool {
+	if stream.Len() != other.Len() {
+		return false
+	}
+	if stream.order != other.order {
+		return stream.Compare(other) == 0
+	}
+	for index := range stream.bits {
+		if stream.bits[index] != other.bits[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the bits as a string of '0' and '1'.
+func (stream *BitStream) String() string {
+	var builder strings.Builder
+	builder.Grow(stream.Len())
+	for bit := range stream.Iter() {
+		builder.WriteByte('0' + bit)
+	}
+	return builder.String()
+}
@@ -1,7 +1,9 @@
 package bitio
 
 import (
+	"bytes"
 	"slices"
+	"strings"
 	"testing"
 )
 
@@ -202,3 +204,229 @@ func TestBitStream(t *testing.T) {
 		})
 	})
 }
+
+func TestBitStreamLSBFirst(t *testing.T) {
+	stream := NewBitStreamWithOrder(LSBFirst)
+	bits := []byte{}
+	for i := range 70 {
+		bit := byte(i % 3 & 1)
+		bits = append(bits, bit)
+		stream.Push(bit)
+	}
+	if stream.bits[0]&0b111 != 0b010 {
+		t.Fatalf("invalid stream.bits value, expected low bits: 0b010, got: %#b", stream.bits[0]&0b111)
+	}
+	if result := slices.Collect(stream.Iter()); !slices.Equal(result, bits) {
+		t.Fatalf("invalid bits, expected: %v, got: %v", bits, result)
+	}
+	if copied := BitStreamCopy(stream); copied.order != LSBFirst || !slices.Equal(slices.Collect(copied.Iter()), bits) {
+		t.Fatal("copy does not keep the bit order")
+	}
+}
+
+// bitStreamOf builds a stream from a string of '0' and '1'.
+func bitStreamOf(code string, order BitOrder) *BitStream {
+	stream := NewBitStreamWithOrder(order)
+	for _, char := range code {
+		stream.Push(byte(char - '0'))
+	}
+	return stream
+}
+
+func TestBitStreamAppend(t *testing.T) {
+	for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+		t.Run("values", func(t *testing.T) {
+			stream, expected := NewBitStreamWithOrder(order), NewBitStreamWithOrder(order)
+			values := []uint64{0x9e3779b97f4a7c15, 0xffffffffffffffff, 0, 0x0123456789abcdef}
+			for n := range 65 {
+				value := values[n%len(values)]
+				stream.Append(value, byte(n))
+				for i := range n {
+					if order == LSBFirst {
+						expected.Push(byte(value >> i & 1))
+					} else {
+						expected.Push(byte(value >> (n - 1 - i) & 1))
+					}
+				}
+			}
+			if stream.Len() != 64*65/2 {
+				t.Fatalf("invalid length, expected: %d, got: %d", 64*65/2, stream.Len())
+			}
+			if !slices.Equal(stream.bits, expected.bits) || stream.position != expected.position {
+				t.Fatal("Append differs from pushing the bits one by one")
+			}
+		})
+
+		t.Run("streams", func(t *testing.T) {
+			for _, first := range []int{0, 5, 64, 70} {
+				for _, second := range []int{0, 3, 64, 130} {
+					a, b := NewBitStreamWithOrder(order), NewBitStreamWithOrder(order)
+					other := NewBitStreamWithOrder(1 - order)
+					expected := []byte{}
+					for i := range first {
+						a.Push(byte(i % 3 & 1))
+						expected = append(expected, byte(i%3&1))
+					}
+					for i := range second {
+						b.Push(byte(i % 5 & 1))
+						other.Push(byte(i % 5 & 1))
+						expected = append(expected, byte(i%5&1))
+					}
+					c := BitStreamCopy(a)
+					a.AppendStream(b)
+					c.AppendStream(other)
+					if result := slices.Collect(a.Iter()); !slices.Equal(result, expected) {
+						t.Fatalf("%d+%d: invalid bits, expected: %v, got: %v", first, second, expected, result)
+					}
+					if !a.Equal(c) {
+						t.Fatalf("%d+%d: appending a stream in another bit order differs", first, second)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("itself", func(t *testing.T) {
+		stream := bitStreamOf("101", MSBFirst)
+		stream.AppendStream(stream)
+		if stream.String() != "101101" {
+			t.Fatalf("invalid bits, expected: 101101, got: %s", stream.String())
+		}
+	})
+}
+
+func TestBitStreamAccess(t *testing.T) {
+	t.Run("Get and Set", func(t *testing.T) {
+		for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+			stream := NewBitStreamWithOrder(order)
+			stream.Append(0, 64)
+			stream.Append(0, 10)
+			for _, index := range []int{0, 13, 63, 64, 73} {
+				stream.Set(index, 1)
+				if stream.Get(index) != 1 {
+					t.Fatalf("bit %d was not set", index)
+				}
+			}
+			stream.Set(13, 0)
+			expected := "1000000000000000000000000000000000000000000000000000000000000001" + "1000000001"
+			if stream.String() != expected {
+				t.Fatalf("invalid bits, expected: %s, got: %s", expected, stream.String())
+			}
+		}
+	})
+
+	t.Run("out of range", func(t *testing.T) {
+		stream := bitStreamOf("101", MSBFirst)
+		for _, index := range []int{-1, 3, 64} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Fatalf("expected panic for index %d", index)
+					}
+				}()
+				stream.Get(index)
+			}()
+		}
+	})
+
+	t.Run("Slice", func(t *testing.T) {
+		code := "1100101011110000101010101111000011001100101010101111000011110000111"
+		stream := bitStreamOf(code, LSBFirst)
+		for _, bounds := range [][2]int{{0, 0}, {0, len(code)}, {3, 9}, {60, 67}, {64, 64}} {
+			slice := stream.Slice(bounds[0], bounds[1])
+			if slice.String() != code[bounds[0]:bounds[1]] || slice.order != LSBFirst {
+				t.Fatalf("%v: expected: %s, got: %s", bounds, code[bounds[0]:bounds[1]], slice.String())
+			}
+		}
+	})
+}
+
+func TestBitStreamBytes(t *testing.T) {
+	testCases := []struct {
+		code    string
+		order   BitOrder
+		bytes   []byte
+		padding byte
+	}{
+		{"", MSBFirst, []byte{}, 0},
+		{"1", MSBFirst, []byte{0b10000000}, 7},
+		{"1", LSBFirst, []byte{0b00000001}, 7},
+		{"01001010101", MSBFirst, []byte{0b01001010, 0b10100000}, 5},
+		{"01001010101", LSBFirst, []byte{0b01010010, 0b00000101}, 5},
+		{strings.Repeat("10000000", 9), MSBFirst, slices.Repeat([]byte{0x80}, 9), 0},
+		{strings.Repeat("10000000", 9), LSBFirst, slices.Repeat([]byte{0x01}, 9), 0},
+	}
+	for _, tc := range testCases {
+		stream := bitStreamOf(tc.code, tc.order)
+		data, padding := stream.Bytes()
+		if !slices.Equal(data, tc.bytes) || padding != tc.padding {
+			t.Fatalf("%q: expected: %08b, %d, got: %08b, %d", tc.code, tc.bytes, tc.padding, data, padding)
+		}
+		parsed, err := BitStreamFromBytes(data, padding, tc.order)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.code, err)
+		}
+		if !parsed.Equal(stream) || parsed.String() != tc.code {
+			t.Fatalf("%q: invalid stream from bytes: %s", tc.code, parsed.String())
+		}
+	}
+
+	t.Run("invalid padding", func(t *testing.T) {
+		if _, err := BitStreamFromBytes([]byte{1}, 8, MSBFirst); err == nil {
+			t.Fatal("expected error for 8 padding bits")
+		}
+		if _, err := BitStreamFromBytes(nil, 1, MSBFirst); err == nil {
+			t.Fatal("expected error for padding without bytes")
+		}
+	})
+}
+
+func TestBitStreamWriteTo(t *testing.T) {
+	code := "0100101010100101111111110000000000010010001101" + strings.Repeat("1", 70)
+	for _, streamOrder := range []BitOrder{MSBFirst, LSBFirst} {
+		for _, writerOrder := range []BitOrder{MSBFirst, LSBFirst} {
+			stream := bitStreamOf(code, streamOrder)
+			buf, expected := &bytes.Buffer{}, &bytes.Buffer{}
+			w, e := NewWriterWithOrder(buf, writerOrder), NewWriterWithOrder(expected, writerOrder)
+			w.WriteBits(0xff, 3)
+			e.WriteBits(0xff, 3)
+			if n, err := stream.WriteTo(w); err != nil || n != int64((3+len(code))/8) {
+				t.Fatalf("unexpected result: %d, %v", n, err)
+			}
+			for _, char := range code {
+				e.WriteBit(byte(char - '0'))
+			}
+			w.Flush()
+			e.Flush()
+			if !bytes.Equal(buf.Bytes(), expected.Bytes()) {
+				t.Fatalf("stream %d, writer %d: expected: %08b, got: %08b", streamOrder, writerOrder, expected.Bytes(), buf.Bytes())
+			}
+		}
+	}
+
+	t.Run("io.Writer", func(t *testing.T) {
+		stream := bitStreamOf("01001010101", MSBFirst)
+		buf := &bytes.Buffer{}
+		if n, err := stream.WriteTo(buf); err != nil || n != 2 {
+			t.Fatalf("unexpected result: %d, %v", n, err)
+		}
+		if !bytes.Equal(buf.Bytes(), []byte{0b01001010, 0b10100000}) {
+			t.Fatalf("invalid bytes: %08b", buf.Bytes())
+		}
+	})
+}
+
+func TestBitStreamCompare(t *testing.T) {
+	codes := []string{"", "0", "00", "01", "0111", "1", "10", "11", strings.Repeat("1", 64), strings.Repeat("1", 65)}
+	for _, a := range codes {
+		for _, b := range codes {
+			x, y := bitStreamOf(a, MSBFirst), bitStreamOf(b, LSBFirst)
+			if result := x.Compare(y); result != strings.Compare(a, b) {
+				t.Fatalf("Compare(%q, %q): expected: %d, got: %d", a, b, strings.Compare(a, b), result)
+			}
+			if x.Equal(y) != (a == b) || x.Equal(bitStreamOf(b, MSBFirst)) != (a == b) {
+				t.Fatalf("Equal(%q, %q): invalid result", a, b)
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package bitio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// Universal codes for integers. The unary parts and the leading one of every
+// code come first whatever the bit order; the remaining binary digits are
+// written with WriteBitsUint64, so they follow the bit order of the stream.
+//
+//	Elias gamma  v >= 1: n zeros, a one, the low n bits of v, n = log2(v)
+//	Elias delta  v >= 1: gamma of the bit length n of v, the low n-1 bits
+//	Exp-Golomb k v >= 0: Elias gamma of v+2^k without its first k zeros
+//	Rice k       v >= 0: v>>k ones, a zero, the low k bits of v
+//	varints      LEB128 groups of 7 bits as in encoding/binary
+
+// ErrInvalidCode is returned when the bits read do not form a valid code or
+// decode to a value that does not fit 64 bits.
+var ErrInvalidCode = errors.New("invalid integer code")
+
+func (writer *Writer) writeLeadingOne(zeros byte) error {
+	if err := writer.WriteBitsUint64(0, zeros); err != nil {
+		return err
+	}
+	return writer.WriteBitsUint64(1, 1)
+}
+
+// countBits reads bits equal to bit until the other value and returns their
+// number; more than limit of them is an invalid code.
+func (reader *Reader) countBits(bit byte, limit uint64) (uint64, error) {
+	var count uint64 = 0
+	for {
+		readed, err := reader.ReadBit()
+		if err != nil {
+			return 0, err
+		}
+		if readed != bit {
+			return count, nil
+		}
+		if count == limit {
+			return 0, ErrInvalidCode
+		}
+		count++
+	}
+}
+
+func (writer *Writer) WriteEliasGamma(value uint64) error {
+	if value == 0 {
+		return errors.New("Elias gamma code is defined for values >= 1")
+	}
+	n := byte(bits.Len64(value) - 1)
+	if err := writer.writeLeadingOne(n); err != nil {
+		return err
+	}
+	return writer.WriteBitsUint64(value, n)
+}
+
+func (reader *Reader) ReadEliasGamma() (uint64, error) {
+	n, err := reader.countBits(0, 63)
+	if err != nil {
+		return 0, err
+	}
+	value, err := reader.ReadBitsUint64(byte(n))
+	if err != nil {
+		return 0, err
+	}
+	return 1<<n | value, nil
+}
+
+func (writer *Writer) WriteEliasDelta(value uint64) error {
+	if value == 0 {
+		return errors.New("Elias delta code is defined for values >= 1")
+	}
+	n := bits.Len64(value)
+	if err := writer.WriteEliasGamma(uint64(n)); err != nil {
+		return err
+	}
+	return writer.WriteBitsUint64(value, byte(n-1))
+}
+
+func (reader *Reader) ReadEliasDelta() (uint64, error) {
+	n, err := reader.ReadEliasGamma()
+	if err != nil {
+		return 0, err
+	}
+	if n > 64 {
+		return 0, ErrInvalidCode
+	}
+	value, err := reader.ReadBitsUint64(byte(n - 1))
+	if err != nil {
+		return 0, err
+	}
+	return 1<<(n-1) | value, nil
+}
+
+// WriteExpGolomb writes value with the Exp-Golomb code of order k, k < 64.
+func (writer *Writer) WriteExpGolomb(value uint64, k byte) error {
+	if k >= 64 {
+		return fmt.Errorf("invalid Exp-Golomb order: %d", k)
+	}
+	if value > math.MaxUint64-(1<<k) {
+		return fmt.Errorf("value is too large for Exp-Golomb code of order %d: %d", k, value)
+	}
+	value += 1 << k
+	n := byte(bits.Len64(value) - 1)
+	if err := writer.writeLeadingOne(n - k); err != nil {
+		return err
+	}
+	return writer.WriteBitsUint64(value, n)
+}
+
+func (reader *Reader) ReadExpGolomb(k byte) (uint64, error) {
+	if k >= 64 {
+		return 0, fmt.Errorf("invalid Exp-Golomb order: %d", k)
+	}
+	zeros, err := reader.countBits(0, uint64(63-k))
+	if err != nil {
+		return 0, err
+	}
+	n := byte(zeros) + k
+	value, err := reader.ReadBitsUint64(n)
+	if err != nil {
+		return 0, err
+	}
+	return (1<<n | value) - 1<<k, nil
+}
+
+// WriteRice writes value with the Rice code of parameter k, k < 64. The
+// quotient value>>k is written in unary, so k should fit the expected values.
+func (writer *Writer) WriteRice(value uint64, k byte) error {
+	if k >= 64 {
+		return fmt.Errorf("invalid Rice parameter: %d", k)
+	}
+	for quotient := value >> k; quotient > 0; {
+		n := byte(min(quotient, 64))
+		if err := writer.WriteBitsUint64(math.MaxUint64, n); err != nil {
+			return err
+		}
+		quotient -= uint64(n)
+	}
+	if err := writer.WriteBitsUint64(0, 1); err != nil {
+		return err
+	}
+	return writer.WriteBitsUint64(value, k)
+}
+
+func (reader *Reader) ReadRice(k byte) (uint64, error) {
+	if k >= 64 {
+		return 0, fmt.Errorf("invalid Rice parameter: %d", k)
+	}
+	quotient, err := reader.countBits(1, math.MaxUint64>>k)
+	if err != nil {
+		return 0, err
+	}
+	remainder, err := reader.ReadBitsUint64(k)
+	if err != nil {
+		return 0, err
+	}
+	return quotient<<k | remainder, nil
+}
+
+func (writer *Writer) WriteUvarint(value uint64) error {
+	_, err := writer.Write(binary.AppendUvarint(nil, value))
+	return err
+}
+
+// ReadUvarint reads a value written by WriteUvarint. It returns io.EOF only
+// if no bits were read and io.ErrUnexpectedEOF for a truncated value.
+func (reader *Reader) ReadUvarint() (uint64, error) {
+	var value uint64 = 0
+	for i := range binary.MaxVarintLen64 {
+		b, err := reader.ReadByte()
+		if err != nil {
+			if i > 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		if i == binary.MaxVarintLen64-1 && b > 1 {
+			return 0, ErrInvalidCode
+		}
+		value |= uint64(b&0x7f) << (7 * i)
+		if b < 0x80 {
+			return value, nil
+		}
+	}
+	return 0, ErrInvalidCode
+}
+
+// WriteVarint writes a signed value in the zig-zag encoding of
+// encoding/binary.
+func (writer *Writer) WriteVarint(value int64) error {
+	_, err := writer.Write(binary.AppendVarint(nil, value))
+	return err
+}
+
+func (reader *Reader) ReadVarint() (int64, error) {
+	value, err := reader.ReadUvarint()
+	return int64(value>>1) ^ -int64(value&1), err
+}
@@ -0,0 +1,175 @@
+package bitio
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+// codeString writes with write and returns the written bits as '0' and '1'.
+func codeString(t *testing.T, write func(*Writer) error) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	if err := write(w); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	length := w.BitsWritten()
+	w.Flush()
+	stream, err := BitStreamFromBytes(buf.Bytes(), byte(int64(buf.Len())*8-length), MSBFirst)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return stream.String()
+}
+
+func TestIntegerCodes(t *testing.T) {
+	testCases := []struct {
+		name  string
+		write func(*Writer) error
+		code  string
+	}{
+		{"gamma 1", func(w *Writer) error { return w.WriteEliasGamma(1) }, "1"},
+		{"gamma 2", func(w *Writer) error { return w.WriteEliasGamma(2) }, "010"},
+		{"gamma 5", func(w *Writer) error { return w.WriteEliasGamma(5) }, "00101"},
+		{"delta 1", func(w *Writer) error { return w.WriteEliasDelta(1) }, "1"},
+		{"delta 2", func(w *Writer) error { return w.WriteEliasDelta(2) }, "0100"},
+		{"delta 10", func(w *Writer) error { return w.WriteEliasDelta(10) }, "00100010"},
+		{"exp-golomb 0, k 0", func(w *Writer) error { return w.WriteExpGolomb(0, 0) }, "1"},
+		{"exp-golomb 3, k 0", func(w *Writer) error { return w.WriteExpGolomb(3, 0) }, "00100"},
+		{"exp-golomb 0, k 1", func(w *Writer) error { return w.WriteExpGolomb(0, 1) }, "10"},
+		{"exp-golomb 2, k 1", func(w *Writer) error { return w.WriteExpGolomb(2, 1) }, "0100"},
+		{"rice 5, k 2", func(w *Writer) error { return w.WriteRice(5, 2) }, "1001"},
+		{"rice 0, k 0", func(w *Writer) error { return w.WriteRice(0, 0) }, "0"},
+		{"rice 3, k 0", func(w *Writer) error { return w.WriteRice(3, 0) }, "1110"},
+		{"uvarint 300", func(w *Writer) error { return w.WriteUvarint(300) }, "1010110000000010"},
+		{"varint -2", func(w *Writer) error { return w.WriteVarint(-2) }, "00000011"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if code := codeString(t, tc.write); code != tc.code {
+				t.Fatalf("invalid code, expected: %s, got: %s", tc.code, code)
+			}
+		})
+	}
+}
+
+func TestIntegerCodesRoundTrip(t *testing.T) {
+	values := []uint64{0, 1, 2, 3, 4, 7, 8, 100, 255, 256, 1 << 20, 1<<32 - 1, 1 << 40, 1<<63 - 1, 1 << 63, math.MaxUint64 - 1, math.MaxUint64}
+	type code struct {
+		name  string
+		write func(*Writer, uint64) error
+		read  func(*Reader) (uint64, error)
+		valid func(uint64) bool
+	}
+	codes := []code{
+		{"gamma", (*Writer).WriteEliasGamma, (*Reader).ReadEliasGamma, func(v uint64) bool { return v > 0 }},
+		{"delta", (*Writer).WriteEliasDelta, (*Reader).ReadEliasDelta, func(v uint64) bool { return v > 0 }},
+		{"uvarint", (*Writer).WriteUvarint, (*Reader).ReadUvarint, func(uint64) bool { return true }},
+		{
+			"varint",
+			func(w *Writer, v uint64) error { return w.WriteVarint(int64(v)) },
+			func(r *Reader) (uint64, error) { v, err := r.ReadVarint(); return uint64(v), err },
+			func(uint64) bool { return true },
+		},
+	}
+	for _, k := range []byte{0, 1, 5, 63} {
+		codes = append(codes, code{
+			"exp-golomb",
+			func(w *Writer, v uint64) error { return w.WriteExpGolomb(v, k) },
+			func(r *Reader) (uint64, error) { return r.ReadExpGolomb(k) },
+			func(v uint64) bool { return v <= math.MaxUint64-1<<k },
+		})
+		codes = append(codes, code{
+			"rice",
+			func(w *Writer, v uint64) error { return w.WriteRice(v, k) },
+			func(r *Reader) (uint64, error) { return r.ReadRice(k) },
+			func(v uint64) bool { return v>>k < 1<<12 },
+		})
+	}
+	for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+		for _, code := range codes {
+			buf := &bytes.Buffer{}
+			w := NewWriterWithOrder(buf, order)
+			written := []uint64{}
+			for _, value := range values {
+				if !code.valid(value) {
+					continue
+				}
+				// a bit in front of every value checks codes off byte boundaries
+				w.WriteBit(1)
+				if err := code.write(w, value); err != nil {
+					t.Fatalf("%s, %d: unexpected error: %v", code.name, value, err)
+				}
+				written = append(written, value)
+			}
+			w.Flush()
+			r := NewReaderWithOrder(buf, order)
+			for _, value := range written {
+				r.ReadBit()
+				if result, err := code.read(r); err != nil || result != value {
+					t.Fatalf("%s, order %d: expected: %d, got: %d, %v", code.name, order, value, result, err)
+				}
+			}
+		}
+	}
+}
+
+func TestIntegerCodesInvalid(t *testing.T) {
+	t.Run("values out of range", func(t *testing.T) {
+		w := NewWriter(&bytes.Buffer{})
+		if err := w.WriteEliasGamma(0); err == nil {
+			t.Fatal("expected error for gamma code of 0")
+		}
+		if err := w.WriteEliasDelta(0); err == nil {
+			t.Fatal("expected error for delta code of 0")
+		}
+		if err := w.WriteExpGolomb(math.MaxUint64, 0); err == nil {
+			t.Fatal("expected error for too large Exp-Golomb value")
+		}
+		if err := w.WriteExpGolomb(0, 64); err == nil {
+			t.Fatal("expected error for Exp-Golomb order 64")
+		}
+		if err := w.WriteRice(0, 64); err == nil {
+			t.Fatal("expected error for Rice parameter 64")
+		}
+		if w.BitsWritten() != 0 {
+			t.Fatalf("invalid values must not be written, bits written: %d", w.BitsWritten())
+		}
+	})
+
+	t.Run("too long codes", func(t *testing.T) {
+		zeros := make([]byte, 9)
+		if _, err := NewReader(bytes.NewReader(zeros)).ReadEliasGamma(); err != ErrInvalidCode {
+			t.Fatalf("expected ErrInvalidCode, got: %v", err)
+		}
+		if _, err := NewReader(bytes.NewReader(zeros)).ReadExpGolomb(3); err != ErrInvalidCode {
+			t.Fatalf("expected ErrInvalidCode, got: %v", err)
+		}
+		// gamma code of 65 as the length of a delta code
+		delta := []byte{0b00000010, 0b00001000}
+		if _, err := NewReader(bytes.NewReader(delta)).ReadEliasDelta(); err != ErrInvalidCode {
+			t.Fatalf("expected ErrInvalidCode, got: %v", err)
+		}
+		overflow := bytes.Repeat([]byte{0xff}, 11)
+		if _, err := NewReader(bytes.NewReader(overflow)).ReadUvarint(); err != ErrInvalidCode {
+			t.Fatalf("expected ErrInvalidCode, got: %v", err)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		if _, err := NewReader(bytes.NewReader([]byte{0b00000001})).ReadEliasGamma(); err != io.EOF {
+			t.Fatalf("expected EOF, got: %v", err)
+		}
+		if _, err := NewReader(bytes.NewReader([]byte{0xff})).ReadRice(1); err != io.EOF {
+			t.Fatalf("expected EOF, got: %v", err)
+		}
+		if _, err := NewReader(bytes.NewReader(nil)).ReadUvarint(); err != io.EOF {
+			t.Fatalf("expected EOF, got: %v", err)
+		}
+		if _, err := NewReader(bytes.NewReader([]byte{0x80})).ReadUvarint(); err != io.ErrUnexpectedEOF {
+			t.Fatalf("expected ErrUnexpectedEOF, got: %v", err)
+		}
+	})
+}
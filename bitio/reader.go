@@ -2,51 +2,188 @@ package bitio
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 )
 
+// ErrNotSeekable is returned by SeekBit for readers not created by
+// NewReaderAt.
+var ErrNotSeekable = errors.New("reader is not seekable")
+
 type Reader struct {
-	in bufio.Reader
+	in *bufio.Reader
+	// buffer is owned by the reader and reused by Reset; in is either
+	// buffer or a *bufio.Reader passed in by the caller
+	buffer *bufio.Reader
+	// source is set for readers created by NewReaderAt, which can seek
+	source *io.SectionReader
 
-	cache     byte
+	// cache holds cacheSize unread bits starting from its most significant
+	// bit, or from its least significant bit with LSBFirst; the rest of it is
+	// zero. It keeps less than a byte unless bits were peeked.
+	cache     uint64
 	cacheSize byte
+	order     BitOrder
+	bitsRead  int64
 }
 
 func NewReader(reader io.Reader) *Reader {
-	return &Reader{*bufio.NewReader(reader), 0, 0}
+	return NewReaderWithOrder(reader, MSBFirst)
 }
 
-func (reader *Reader) ReadBit() (byte, error) {
-	if reader.cacheSize > 0 {
-		value := (reader.cache & 0b10000000) >> 7
-		reader.cache <<= 1
-		reader.cacheSize -= 1
-		return value, nil
+func NewReaderWithOrder(reader io.Reader, order BitOrder) *Reader {
+	result := &Reader{order: order}
+	result.Reset(reader)
+	return result
+}
+
+// NewReaderAt returns a reader over the first size bytes of reader that can
+// jump to any bit with SeekBit.
+func NewReaderAt(reader io.ReaderAt, size int64) *Reader {
+	return NewReaderAtWithOrder(reader, size, MSBFirst)
+}
+
+func NewReaderAtWithOrder(reader io.ReaderAt, size int64, order BitOrder) *Reader {
+	source := io.NewSectionReader(reader, 0, size)
+	result := NewReaderWithOrder(source, order)
+	result.source = source
+	return result
+}
+
+// Reset discards all state and makes the reader read from reader in the same
+// bit order, reusing its buffer. A *bufio.Reader is read directly, as with
+// NewReader.
+func (reader *Reader) Reset(r io.Reader) {
+	if buffered, ok := r.(*bufio.Reader); ok && buffered.Size() >= bufferSize {
+		reader.in = buffered
+	} else {
+		if reader.buffer == nil {
+			reader.buffer = bufio.NewReaderSize(r, bufferSize)
+		} else {
+			reader.buffer.Reset(r)
+		}
+		reader.in = reader.buffer
 	}
-	readed, err := reader.in.ReadByte()
-	if err != nil {
+	reader.source = nil
+	reader.cache, reader.cacheSize = 0, 0
+	reader.bitsRead = 0
+}
+
+// SeekBit moves a reader created by NewReaderAt to the bit at offset,
+// counted from the start of its input. Cached and peeked bits are dropped
+// and BitsRead becomes offset.
+func (reader *Reader) SeekBit(offset int64) error {
+	if reader.source == nil {
+		return ErrNotSeekable
+	}
+	if offset < 0 || offset > reader.source.Size()*8 {
+		return fmt.Errorf("invalid bit offset: %d", offset)
+	}
+	if _, err := reader.source.Seek(offset/8, io.SeekStart); err != nil {
+		return err
+	}
+	reader.in.Reset(reader.source)
+	reader.cache, reader.cacheSize = 0, 0
+	reader.bitsRead = offset / 8 * 8
+	return reader.Consume(byte(offset % 8))
+}
+
+// fill reads bytes until at least n bits, n <= 56, are cached.
+func (reader *Reader) fill(n byte) error {
+	for reader.cacheSize < n {
+		readed, err := reader.in.ReadByte()
+		if err != nil {
+			return err
+		}
+		if reader.order == LSBFirst {
+			reader.cache |= uint64(readed) << reader.cacheSize
+		} else {
+			reader.cache |= uint64(readed) << (56 - reader.cacheSize)
+		}
+		reader.cacheSize += 8
+	}
+	return nil
+}
+
+func (reader *Reader) peek(n byte) uint64 {
+	if reader.order == LSBFirst {
+		return reader.cache & (1<<n - 1)
+	}
+	return reader.cache >> (64 - n)
+}
+
+func (reader *Reader) skip(n byte) {
+	if reader.order == LSBFirst {
+		reader.cache >>= n
+	} else {
+		reader.cache <<= n
+	}
+	reader.cacheSize -= n
+	reader.bitsRead += int64(n)
+}
+
+// ReadBitsUint64 reads n bits, n <= 64, and returns them right-aligned: the
+// first bit read is bit n-1 of the result, or bit 0 with LSBFirst.
+func (reader *Reader) ReadBitsUint64(n byte) (uint64, error) {
+	if n > 64 {
+		return 0, fmt.Errorf("invalid number of bits to read: %d", n)
+	}
+	if n > 56 {
+		// up to 7 cached bits and 8 more from every byte do not fit 64 bits
+		first, err := reader.ReadBitsUint64(n - 32)
+		if err != nil {
+			return 0, err
+		}
+		second, err := reader.ReadBitsUint64(32)
+		if reader.order == LSBFirst {
+			return second<<(n-32) | first, err
+		}
+		return first<<32 | second, err
+	}
+	if err := reader.fill(n); err != nil {
 		return 0, err
 	}
-	value := (readed & 0b10000000) >> 7
-	reader.cache = readed << 1
-	reader.cacheSize = 7
+	value := reader.peek(n)
+	reader.skip(n)
 	return value, nil
 }
 
-func (reader *Reader) ReadByte() (byte, error) {
-	readed, err := reader.in.ReadByte()
-	if reader.cacheSize == 0 || err != nil {
-		return readed, err
+// PeekBits returns the next n bits, n <= 56, right-aligned as ReadBitsUint64
+// does, without consuming them, and the number of bits available. Near the
+// end of input fewer than n bits may be available; the missing ones are
+// zeros. Running out of input is not an error.
+func (reader *Reader) PeekBits(n byte) (uint64, byte, error) {
+	if n > 56 {
+		return 0, 0, fmt.Errorf("invalid number of bits to peek: %d", n)
+	}
+	if err := reader.fill(n); err != nil && err != io.EOF {
+		return 0, 0, err
 	}
-	result := reader.cache | (readed >> reader.cacheSize)
-	reader.cache = readed << (8 - reader.cacheSize)
-	return result, nil
+	return reader.peek(n), min(n, reader.cacheSize), nil
+}
+
+// Consume skips n bits, n <= 64, usually after PeekBits.
+func (reader *Reader) Consume(n byte) error {
+	_, err := reader.ReadBitsUint64(n)
+	return err
+}
+
+func (reader *Reader) ReadBit() (byte, error) {
+	value, err := reader.ReadBitsUint64(1)
+	return byte(value), err
+}
+
+func (reader *Reader) ReadByte() (byte, error) {
+	value, err := reader.ReadBitsUint64(8)
+	return byte(value), err
 }
 
 func (reader *Reader) Read(buffer []byte) (int, error) {
 	if reader.cacheSize == 0 {
-		return reader.in.Read(buffer)
+		readed, err := reader.in.Read(buffer)
+		reader.bitsRead += 8 * int64(readed)
+		return readed, err
 	}
 	for index := range buffer {
 		if b, err := reader.ReadByte(); err != nil {
@@ -59,25 +196,35 @@ func (reader *Reader) Read(buffer []byte) (int, error) {
 }
 
 func (reader *Reader) Align() error {
-	for reader.cacheSize != 0 {
-		if _, err := reader.ReadBit(); err != nil {
-			return err
-		}
-	}
+	// bits peeked from the following bytes stay in the cache
+	reader.skip(reader.cacheSize % 8)
 	return nil
 }
 
+// ReadBits reads number bits, number <= 8, and returns them starting from
+// the most significant bit of the result, or right-aligned with LSBFirst.
 func (reader *Reader) ReadBits(number byte) (byte, error) {
 	if number > 8 {
 		return 0, fmt.Errorf("invalid number of bytes to read: %d", number)
 	}
-	var result byte = 0
-	for i := range number {
-		bit, err := reader.ReadBit()
-		if err != nil {
-			return result, err
-		}
-		result |= bit << (7 - i)
+	value, err := reader.ReadBitsUint64(number)
+	if err != nil {
+		return 0, err
 	}
-	return result, nil
+	if reader.order == LSBFirst {
+		return byte(value), nil
+	}
+	return byte(value << (8 - number)), nil
+}
+
+// BitsRead returns the number of bits read, skipped or aligned over so far.
+// Peeked bits are not counted until they are consumed.
+func (reader *Reader) BitsRead() int64 {
+	return reader.bitsRead
+}
+
+// Offset returns the position of the next bit to read as a byte offset and
+// the index of the bit within that byte.
+func (reader *Reader) Offset() (int64, byte) {
+	return reader.bitsRead / 8, byte(reader.bitsRead % 8)
 }
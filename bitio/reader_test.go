@@ -1,6 +1,7 @@
 package bitio
 
 import (
+	"bufio"
 	"bytes"
 	"io"
 	"testing"
@@ -454,7 +455,7 @@ func TestCombinations(t *testing.T) {
 			t.Fatalf("invalid bytes readed, expected: %v, got: %v", expected, buffer)
 		}
 
-		if r.cache != 0b11110000 || r.cacheSize != 4 {
+		if r.cache != 0b11110000<<56 || r.cacheSize != 4 {
 			t.Fatalf("invalud cache or cache size values, cache: %#b, %d", r.cache, r.cacheSize)
 		}
 
@@ -495,3 +496,472 @@ func TestCombinations(t *testing.T) {
 		}
 	})
 }
+
+func TestReadBitsUint64(t *testing.T) {
+	source := []byte{0b01001010, 0b10100101, 0xff, 0x00, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc}
+
+	t.Run("empty buffer", func(t *testing.T) {
+		r := NewReader(bytes.NewBuffer([]byte{}))
+		if res, err := r.ReadBitsUint64(13); err != io.EOF {
+			t.Fatalf("EOF error expected, got: %d, %v", res, err)
+		}
+	})
+
+	t.Run("read 0 bits", func(t *testing.T) {
+		r := NewReader(bytes.NewBuffer(source))
+		if res, err := r.ReadBitsUint64(0); err != nil || res != 0 {
+			t.Fatalf("invalid result after reading 0 bits: %d, %v", res, err)
+		}
+	})
+
+	t.Run("invalid number of bits to read", func(t *testing.T) {
+		r := NewReader(bytes.NewBuffer(source))
+		if _, err := r.ReadBitsUint64(65); err == nil {
+			t.Fatal("no error when reading > 64 bits")
+		}
+	})
+
+	t.Run("EOF", func(t *testing.T) {
+		r := NewReader(bytes.NewBuffer(source[:2]))
+		r.ReadBit()
+		if _, err := r.ReadBitsUint64(16); err != io.EOF {
+			t.Fatalf("expected EOF but got: %v", err)
+		}
+	})
+
+	t.Run("reading 64 bits", func(t *testing.T) {
+		const expected = 0x4aa5ff0012345678
+		r := NewReader(bytes.NewBuffer(source))
+		res, err := r.ReadBitsUint64(64)
+		if err != nil {
+			t.Fatalf("unexpected error while reading 64 bits: %v", err)
+		}
+		if res != expected {
+			t.Fatalf("invalid result of reading 64 bits, expected: %#x, got: %#x", expected, res)
+		}
+	})
+
+	t.Run("read bits across boundary", func(t *testing.T) {
+		r := NewReader(bytes.NewBuffer(source))
+		r.ReadBits(5)
+		res, err := r.ReadBitsUint64(13)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if res != 0b0101010010111 {
+			t.Fatalf("invalid result value, expected: %#b, got: %#b", 0b0101010010111, res)
+		}
+		if r.cacheSize != 6 {
+			t.Fatalf("invalid cache size, expected: 6, got: %d", r.cacheSize)
+		}
+	})
+
+	t.Run("ReadBitsUint64 + ReadBit", func(t *testing.T) {
+		// brute force: every offset and length against bit-by-bit reading
+		for offset := range 8 {
+			for n := range 65 {
+				if offset+n > len(source)*8 {
+					continue
+				}
+				r := NewReader(bytes.NewBuffer(source))
+				expected := NewReader(bytes.NewBuffer(source))
+				for range offset {
+					r.ReadBit()
+					expected.ReadBit()
+				}
+				var value uint64 = 0
+				for range n {
+					bit, _ := expected.ReadBit()
+					value = value<<1 | uint64(bit)
+				}
+				res, err := r.ReadBitsUint64(byte(n))
+				if err != nil {
+					t.Fatalf("offset %d, n %d: unexpected error: %v", offset, n, err)
+				}
+				if res != value {
+					t.Fatalf("offset %d, n %d: expected: %#x, got: %#x", offset, n, value, res)
+				}
+				for {
+					want, wantErr := expected.ReadBit()
+					bit, err := r.ReadBit()
+					if bit != want || err != wantErr {
+						t.Fatalf("offset %d, n %d: invalid bit after ReadBitsUint64", offset, n)
+					}
+					if err != nil {
+						break
+					}
+				}
+			}
+		}
+	})
+}
+
+func TestPeekBits(t *testing.T) {
+	t.Run("empty buffer", func(t *testing.T) {
+		r := NewReader(bytes.NewBuffer([]byte{}))
+		res, count, err := r.PeekBits(9)
+		if res != 0 || count != 0 || err != nil {
+			t.Fatalf("expected no bits, got: %d, %d, %v", res, count, err)
+		}
+	})
+
+	t.Run("invalid number of bits to peek", func(t *testing.T) {
+		r := NewReader(bytes.NewBuffer([]byte{1}))
+		if _, _, err := r.PeekBits(57); err == nil {
+			t.Fatal("no error when peeking > 56 bits")
+		}
+	})
+
+	t.Run("peek does not consume", func(t *testing.T) {
+		r := NewReader(bytes.NewBuffer([]byte{0b01001010, 0b10100101}))
+		for range 3 {
+			res, count, err := r.PeekBits(12)
+			if err != nil || count != 12 || res != 0b010010101010 {
+				t.Fatalf("invalid peek result: %#b, %d, %v", res, count, err)
+			}
+		}
+		if res, err := r.ReadBitsUint64(16); err != nil || res != 0b0100101010100101 {
+			t.Fatalf("invalid bits after peeking: %#b, %v", res, err)
+		}
+	})
+
+	t.Run("padding near EOF", func(t *testing.T) {
+		r := NewReader(bytes.NewBuffer([]byte{0b01001010, 0b10100101}))
+		r.ReadBits(5)
+		res, count, err := r.PeekBits(15)
+		if err != nil || count != 11 || res != 0b010101001010000 {
+			t.Fatalf("invalid peek result: %#b, %d, %v", res, count, err)
+		}
+	})
+
+	t.Run("consume", func(t *testing.T) {
+		r := NewReader(bytes.NewBuffer([]byte{0b01001010, 0b10100101}))
+		r.PeekBits(16)
+		if err := r.Consume(3); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if res, _ := r.ReadBits(8); res != 0b01010101 {
+			t.Fatalf("invalid bits after consuming: %#08b", res)
+		}
+		if err := r.Consume(6); err != io.EOF {
+			t.Fatalf("expected EOF when consuming missing bits, got: %v", err)
+		}
+	})
+
+	t.Run("align after peek", func(t *testing.T) {
+		r := NewReader(bytes.NewBuffer([]byte{0b01001010, 0b10100101, 0xff}))
+		r.ReadBits(3)
+		r.PeekBits(20)
+		r.Align()
+		if res, err := r.ReadByte(); err != nil || res != 0b10100101 {
+			t.Fatalf("invalid byte after aligning: %#08b, %v", res, err)
+		}
+	})
+}
+
+// naiveReader is the reference for FuzzPeekBits: the input as separate bits.
+type naiveReader struct {
+	bits     []byte
+	position int
+	order    BitOrder
+}
+
+func newNaiveReader(data []byte, order BitOrder) *naiveReader {
+	bits := make([]byte, 0, len(data)*8)
+	for _, b := range data {
+		for i := range 8 {
+			if order == LSBFirst {
+				bits = append(bits, b>>i&1)
+			} else {
+				bits = append(bits, b>>(7-i)&1)
+			}
+		}
+	}
+	return &naiveReader{bits, 0, order}
+}
+
+func (reader *naiveReader) peek(n int) (uint64, int) {
+	var value uint64 = 0
+	count := 0
+	for i := range n {
+		var bit uint64 = 0
+		if reader.position+i < len(reader.bits) {
+			bit = uint64(reader.bits[reader.position+i])
+			count++
+		}
+		if reader.order == LSBFirst {
+			value |= bit << i
+		} else {
+			value = value<<1 | bit
+		}
+	}
+	return value, count
+}
+
+func FuzzPeekBits(f *testing.F) {
+	f.Add([]byte{0b01001010, 0b10100101}, []byte{12, 3, 9, 200}, false)
+	f.Add([]byte{0xff, 0x00, 0x12, 0x34, 0x56, 0x78, 0x9a}, []byte{56, 225, 7, 64, 1}, false)
+	f.Add([]byte{0xff, 0x00, 0x12, 0x34, 0x56, 0x78, 0x9a}, []byte{56, 225, 7, 64, 1}, true)
+	f.Add([]byte{}, []byte{1, 2, 3}, true)
+	f.Fuzz(func(t *testing.T, data []byte, operations []byte, lsb bool) {
+		order := MSBFirst
+		if lsb {
+			order = LSBFirst
+		}
+		r := NewReaderWithOrder(bytes.NewReader(data), order)
+		reference := newNaiveReader(data, order)
+		for _, operation := range operations {
+			n := int(operation>>2) % 57
+			switch operation & 3 {
+			case 0:
+				value, count, err := r.PeekBits(byte(n))
+				expected, expectedCount := reference.peek(n)
+				if err != nil || value != expected || int(count) != expectedCount {
+					t.Fatalf("PeekBits(%d): expected: %#x, %d, got: %#x, %d, %v", n, expected, expectedCount, value, count, err)
+				}
+			case 1:
+				err := r.Consume(byte(n))
+				if _, count := reference.peek(n); count < n {
+					if err != io.EOF {
+						t.Fatalf("Consume(%d): expected EOF, got: %v", n, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Consume(%d): unexpected error: %v", n, err)
+				}
+				reference.position += n
+			case 2:
+				value, err := r.ReadBitsUint64(byte(n))
+				expected, count := reference.peek(n)
+				if count < n {
+					if err != io.EOF {
+						t.Fatalf("ReadBitsUint64(%d): expected EOF, got: %v", n, err)
+					}
+					return
+				}
+				if err != nil || value != expected {
+					t.Fatalf("ReadBitsUint64(%d): expected: %#x, got: %#x, %v", n, expected, value, err)
+				}
+				reference.position += n
+			case 3:
+				r.Align()
+				reference.position = (reference.position + 7) / 8 * 8
+			}
+			if r.BitsRead() != int64(reference.position) {
+				t.Fatalf("BitsRead: expected: %d, got: %d", reference.position, r.BitsRead())
+			}
+		}
+	})
+}
+
+func TestReaderLSBFirst(t *testing.T) {
+	source := []byte{0b01001010, 0b10100101, 0xff}
+
+	t.Run("ReadBit", func(t *testing.T) {
+		r := NewReaderWithOrder(bytes.NewBuffer(source[:1]), LSBFirst)
+		expected := []byte{0, 1, 0, 1, 0, 0, 1, 0}
+		for i, want := range expected {
+			if bit, err := r.ReadBit(); err != nil || bit != want {
+				t.Fatalf("bit %d: expected: %d, got: %d, %v", i, want, bit, err)
+			}
+		}
+		if _, err := r.ReadBit(); err != io.EOF {
+			t.Fatalf("expected EOF, got: %v", err)
+		}
+	})
+
+	t.Run("ReadBits", func(t *testing.T) {
+		r := NewReaderWithOrder(bytes.NewBuffer(source), LSBFirst)
+		if res, _ := r.ReadBits(3); res != 0b010 {
+			t.Fatalf("invalid bits, expected: %#b, got: %#b", 0b010, res)
+		}
+		if res, _ := r.ReadBits(8); res != 0b10101001 {
+			t.Fatalf("invalid bits across boundary, expected: %#b, got: %#b", 0b10101001, res)
+		}
+	})
+
+	t.Run("ReadBitsUint64", func(t *testing.T) {
+		r := NewReaderWithOrder(bytes.NewBuffer(source), LSBFirst)
+		if res, err := r.ReadBitsUint64(24); err != nil || res != 0xffa54a {
+			t.Fatalf("invalid bits, expected: %#x, got: %#x, %v", 0xffa54a, res, err)
+		}
+		long := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0xff}
+		r = NewReaderWithOrder(bytes.NewBuffer(long), LSBFirst)
+		r.ReadBits(4)
+		if res, err := r.ReadBitsUint64(64); err != nil || res != 0xfefcdab896745230 {
+			t.Fatalf("invalid 64 bits, expected: %#x, got: %#x, %v", uint64(0xfefcdab896745230), res, err)
+		}
+	})
+
+	t.Run("ReadByte after bits", func(t *testing.T) {
+		r := NewReaderWithOrder(bytes.NewBuffer(source), LSBFirst)
+		r.ReadBits(4)
+		if res, _ := r.ReadByte(); res != 0b01010100 {
+			t.Fatalf("invalid byte, expected: %#08b, got: %#08b", 0b01010100, res)
+		}
+		r.Align()
+		if res, _ := r.ReadByte(); res != 0xff {
+			t.Fatalf("invalid byte after aligning, expected: %#x, got: %#x", 0xff, res)
+		}
+	})
+
+	t.Run("PeekBits", func(t *testing.T) {
+		r := NewReaderWithOrder(bytes.NewBuffer(source[:2]), LSBFirst)
+		r.ReadBits(5)
+		res, count, err := r.PeekBits(15)
+		if err != nil || count != 11 || res != 0b10100101010 {
+			t.Fatalf("invalid peek result: %#b, %d, %v", res, count, err)
+		}
+	})
+}
+
+func TestBitsRead(t *testing.T) {
+	source := []byte{0b01001010, 0b10100101, 0xff, 0x00, 0x12}
+
+	t.Run("counts every read", func(t *testing.T) {
+		r := NewReader(bytes.NewBuffer(source))
+		steps := []struct {
+			read   func()
+			bits   int64
+			offset int64
+			bit    byte
+		}{
+			{func() { r.ReadBit() }, 1, 0, 1},
+			{func() { r.ReadBits(5) }, 6, 0, 6},
+			{func() { r.ReadByte() }, 14, 1, 6},
+			{func() { r.PeekBits(20) }, 14, 1, 6},
+			{func() { r.Consume(3) }, 17, 2, 1},
+			{func() { r.Align() }, 24, 3, 0},
+			{func() { r.Read(make([]byte, 2)) }, 40, 5, 0},
+			{func() { r.ReadBit() }, 40, 5, 0},
+		}
+		for i, step := range steps {
+			step.read()
+			if r.BitsRead() != step.bits {
+				t.Fatalf("step %d: invalid bits read, expected: %d, got: %d", i, step.bits, r.BitsRead())
+			}
+			if offset, bit := r.Offset(); offset != step.offset || bit != step.bit {
+				t.Fatalf("step %d: invalid offset, expected: (%d, %d), got: (%d, %d)", i, step.offset, step.bit, offset, bit)
+			}
+		}
+	})
+
+	t.Run("Read after bits", func(t *testing.T) {
+		r := NewReaderWithOrder(bytes.NewBuffer(source), LSBFirst)
+		r.ReadBits(3)
+		r.Read(make([]byte, 3))
+		if r.BitsRead() != 27 {
+			t.Fatalf("invalid bits read, expected: %d, got: %d", 27, r.BitsRead())
+		}
+	})
+}
+
+func TestSeekBit(t *testing.T) {
+	source := []byte{0b01001010, 0b10100101, 0xff, 0x00, 0x12, 0x34, 0x56, 0x78, 0x9a}
+
+	t.Run("every offset", func(t *testing.T) {
+		for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+			expected := newNaiveReader(source, order)
+			r := NewReaderAtWithOrder(bytes.NewReader(source), int64(len(source)), order)
+			// seek backwards so that every seek drops cached bits
+			for offset := len(source) * 8; offset >= 0; offset-- {
+				r.PeekBits(40)
+				if err := r.SeekBit(int64(offset)); err != nil {
+					t.Fatalf("offset %d: unexpected error: %v", offset, err)
+				}
+				if r.BitsRead() != int64(offset) {
+					t.Fatalf("offset %d: invalid bits read: %d", offset, r.BitsRead())
+				}
+				n := min(13, len(source)*8-offset)
+				expected.position = offset
+				value, _ := expected.peek(n)
+				if res, err := r.ReadBitsUint64(byte(n)); err != nil || res != value {
+					t.Fatalf("offset %d: expected: %#x, got: %#x, %v", offset, value, res, err)
+				}
+			}
+		}
+	})
+
+	t.Run("read to the end after seeking", func(t *testing.T) {
+		r := NewReaderAt(bytes.NewReader(source), int64(len(source)))
+		r.SeekBit(20)
+		buffer := make([]byte, 8)
+		readed, err := r.Read(buffer)
+		if readed != 6 || err != io.EOF {
+			t.Fatalf("expected 6 bytes and EOF, got: %d, %v", readed, err)
+		}
+		if buffer[0] != 0xf0 || buffer[5] != 0x89 {
+			t.Fatalf("invalid bytes after seeking: %x", buffer[:readed])
+		}
+	})
+
+	t.Run("size limits the input", func(t *testing.T) {
+		r := NewReaderAt(bytes.NewReader(source), 2)
+		r.SeekBit(12)
+		if res, err := r.ReadBitsUint64(4); err != nil || res != 0b0101 {
+			t.Fatalf("invalid bits: %#b, %v", res, err)
+		}
+		if _, err := r.ReadBit(); err != io.EOF {
+			t.Fatalf("expected EOF after size bytes, got: %v", err)
+		}
+	})
+
+	t.Run("invalid offset", func(t *testing.T) {
+		r := NewReaderAt(bytes.NewReader(source), int64(len(source)))
+		for _, offset := range []int64{-1, int64(len(source))*8 + 1} {
+			if err := r.SeekBit(offset); err == nil {
+				t.Fatalf("offset %d: expected error, got: <nil>", offset)
+			}
+		}
+	})
+
+	t.Run("not seekable", func(t *testing.T) {
+		r := NewReader(bytes.NewReader(source))
+		if err := r.SeekBit(0); err != ErrNotSeekable {
+			t.Fatalf("expected ErrNotSeekable, got: %v", err)
+		}
+	})
+}
+
+func TestReaderReset(t *testing.T) {
+	t.Run("drops the state", func(t *testing.T) {
+		for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+			r := NewReaderWithOrder(bytes.NewBuffer([]byte{0xff, 0xff}), order)
+			r.ReadBits(3)
+			r.PeekBits(10)
+			r.Reset(bytes.NewBuffer([]byte{0b01001010, 0b10100101}))
+			if r.BitsRead() != 0 {
+				t.Fatalf("invalid bits read after reset: %d", r.BitsRead())
+			}
+			expected := newNaiveReader([]byte{0b01001010, 0b10100101}, order)
+			value, _ := expected.peek(16)
+			if res, err := r.ReadBitsUint64(16); err != nil || res != value {
+				t.Fatalf("expected: %#x, got: %#x, %v", value, res, err)
+			}
+			if _, err := r.ReadBit(); err != io.EOF {
+				t.Fatalf("expected EOF, got: %v", err)
+			}
+		}
+	})
+
+	t.Run("buffered input is read directly", func(t *testing.T) {
+		buffered := bufio.NewReader(bytes.NewBuffer([]byte{1, 2, 3}))
+		r := NewReader(bytes.NewBuffer(nil))
+		r.Reset(buffered)
+		if b, err := r.ReadByte(); err != nil || b != 1 {
+			t.Fatalf("expected: 1, got: %d, %v", b, err)
+		}
+		if b, err := buffered.ReadByte(); err != nil || b != 2 {
+			t.Fatalf("expected the next byte in the caller's reader: 2, got: %d, %v", b, err)
+		}
+	})
+
+	t.Run("cannot seek after reset", func(t *testing.T) {
+		r := NewReaderAt(bytes.NewReader([]byte{1, 2}), 2)
+		r.Reset(bytes.NewBuffer([]byte{1, 2}))
+		if err := r.SeekBit(0); err != ErrNotSeekable {
+			t.Fatalf("expected ErrNotSeekable, got: %v", err)
+		}
+	})
+}
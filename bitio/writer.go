@@ -6,20 +6,58 @@ import (
 	"io"
 )
 
+// bufferSize is the size of the buffers of readers and writers, the default
+// size of package bufio.
+const bufferSize = 4096
+
 type Writer struct {
-	out bufio.Writer
+	out *bufio.Writer
+	// buffer is owned by the writer and reused by Reset; out is either
+	// buffer or a *bufio.Writer passed in by the caller
+	buffer *bufio.Writer
 
-	cache     byte
-	cacheSize byte
+	// cache holds cacheSize bits that do not make up a whole byte yet,
+	// starting from its most significant bit, or from its least significant
+	// bit with LSBFirst; the rest of it is zero
+	cache       uint64
+	cacheSize   byte
+	order       BitOrder
+	bitsWritten int64
 }
 
 func NewWriter(writer io.Writer) *Writer {
-	return &Writer{*bufio.NewWriter(writer), 0, 0}
+	return NewWriterWithOrder(writer, MSBFirst)
+}
+
+func NewWriterWithOrder(writer io.Writer, order BitOrder) *Writer {
+	result := &Writer{order: order}
+	result.Reset(writer)
+	return result
+}
+
+// Reset discards all unflushed bits and makes the writer write to writer in
+// the same bit order, reusing its buffer. A *bufio.Writer is written to
+// directly, as with NewWriter.
+func (writer *Writer) Reset(w io.Writer) {
+	if buffered, ok := w.(*bufio.Writer); ok && buffered.Size() >= bufferSize {
+		writer.out = buffered
+	} else {
+		if writer.buffer == nil {
+			writer.buffer = bufio.NewWriterSize(w, bufferSize)
+		} else {
+			writer.buffer.Reset(w)
+		}
+		writer.out = writer.buffer
+	}
+	writer.cache, writer.cacheSize = 0, 0
+	writer.bitsWritten = 0
 }
 
 func (writer *Writer) Write(buffer []byte) (int, error) {
 	if writer.cacheSize == 0 {
-		return writer.out.Write(buffer)
+		written, err := writer.out.Write(buffer)
+		writer.bitsWritten += 8 * int64(written)
+		return written, err
 	}
 	for index, b := range buffer {
 		if err := writer.WriteByte(b); err != nil {
@@ -30,52 +68,80 @@ func (writer *Writer) Write(buffer []byte) (int, error) {
 }
 
 func (writer *Writer) WriteByte(b byte) error {
-	if writer.cacheSize == 0 {
-		return writer.out.WriteByte(b)
+	return writer.WriteBitsUint64(uint64(b), 8)
+}
+
+// WriteBitsUint64 writes the n lowest bits of bits, n <= 64, starting from
+// bit n-1, or from bit 0 with LSBFirst.
+func (writer *Writer) WriteBitsUint64(bits uint64, n byte) error {
+	if n > 64 {
+		return fmt.Errorf("invalid number of bits: %d", n)
 	}
-	err := writer.out.WriteByte(writer.cache | b>>writer.cacheSize)
-	if err != nil {
-		return err
+	if n > 56 {
+		// up to 7 cached bits and n more do not fit 64 bits
+		if writer.order == LSBFirst {
+			if err := writer.WriteBitsUint64(bits, 32); err != nil {
+				return err
+			}
+			return writer.WriteBitsUint64(bits>>32, n-32)
+		}
+		if err := writer.WriteBitsUint64(bits>>32, n-32); err != nil {
+			return err
+		}
+		return writer.WriteBitsUint64(bits, 32)
+	}
+	if n == 0 {
+		return nil
+	}
+	bits &= 1<<n - 1
+	if writer.order == LSBFirst {
+		writer.cache |= bits << writer.cacheSize
+	} else {
+		writer.cache |= bits << (64 - n - writer.cacheSize)
+	}
+	writer.cacheSize += n
+	writer.bitsWritten += int64(n)
+	for writer.cacheSize >= 8 {
+		if writer.order == LSBFirst {
+			if err := writer.out.WriteByte(byte(writer.cache)); err != nil {
+				return err
+			}
+			writer.cache >>= 8
+		} else {
+			if err := writer.out.WriteByte(byte(writer.cache >> 56)); err != nil {
+				return err
+			}
+			writer.cache <<= 8
+		}
+		writer.cacheSize -= 8
 	}
-	writer.cache = b << (8 - writer.cacheSize)
 	return nil
 }
 
-func (writer *Writer) WriteBits(bits byte, n byte) (err error) {
+// WriteBits writes the n highest bits of bits, n <= 8, or the n lowest bits
+// with LSBFirst.
+func (writer *Writer) WriteBits(bits byte, n byte) error {
 	if n > 8 {
 		return fmt.Errorf("invalid bytes number: %d", n)
 	}
-
-	bits &= ((1 << n) - 1) << (8 - n)
-
-	if writer.cacheSize+n < 8 {
-		writer.cache |= bits >> writer.cacheSize
-		writer.cacheSize += n
-		return
+	if writer.order == LSBFirst {
+		return writer.WriteBitsUint64(uint64(bits), n)
 	}
-
-	toWrite := writer.cache | (bits >> writer.cacheSize)
-	err = writer.out.WriteByte(toWrite)
-	if err != nil {
-		return
-	}
-	writer.cache = bits << (8 - writer.cacheSize)
-	writer.cacheSize = writer.cacheSize + n - 8
-	return
+	return writer.WriteBitsUint64(uint64(bits>>(8-n)), n)
 }
 
 func (writer *Writer) WriteBit(bit byte) error {
 	if bit > 0 {
-		return writer.WriteBits(0b10000000, 1)
+		return writer.WriteBitsUint64(1, 1)
 	}
-	return writer.WriteBits(0b00000000, 1)
+	return writer.WriteBitsUint64(0, 1)
 }
 
 func (writer *Writer) Align() error {
 	if writer.cacheSize == 0 {
 		return nil
 	}
-	return writer.WriteBits(0, 8-writer.cacheSize)
+	return writer.WriteBitsUint64(0, 8-writer.cacheSize)
 }
 
 func (writer *Writer) Flush() error {
@@ -84,3 +150,15 @@ func (writer *Writer) Flush() error {
 	}
 	return writer.out.Flush()
 }
+
+// BitsWritten returns the number of bits written so far, including the
+// padding added by Align and Flush.
+func (writer *Writer) BitsWritten() int64 {
+	return writer.bitsWritten
+}
+
+// Offset returns the position of the next bit to write as a byte offset and
+// the index of the bit within that byte.
+func (writer *Writer) Offset() (int64, byte) {
+	return writer.bitsWritten / 8, byte(writer.bitsWritten % 8)
+}
@@ -1,6 +1,7 @@
 package bitio
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
 	"testing"
@@ -594,3 +595,255 @@ func TestWriterCombinations(t *testing.T) {
 		}
 	})
 }
+
+func TestWriteBitsUint64(t *testing.T) {
+	t.Run("write 0 bits", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		w := NewWriter(buf)
+		w.WriteBitsUint64(0xffff, 0)
+		w.Flush()
+		if buf.Len() > 0 {
+			t.Fatalf("invalid buffer length when writing 0 bits, expected: 0, got: %d", buf.Len())
+		}
+	})
+
+	t.Run("invalid number of bits", func(t *testing.T) {
+		w := NewWriter(&bytes.Buffer{})
+		if err := w.WriteBitsUint64(0xff, 65); err == nil {
+			t.Fatal("expected error when writing 65 bits, got <nil>")
+		}
+	})
+
+	t.Run("write 64 bits", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		w := NewWriter(buf)
+		if err := w.WriteBitsUint64(0x0123456789abcdef, 64); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if w.cacheSize != 0 {
+			t.Fatalf("invalid cache size, expected: 0, got: %d", w.cacheSize)
+		}
+		w.Flush()
+		expected := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Fatalf("invalid bytes written, expected: %x, got: %x", expected, buf.Bytes())
+		}
+	})
+
+	t.Run("high bits are ignored", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		w := NewWriter(buf)
+		w.WriteBitsUint64(0xfff0, 5)
+		w.Flush()
+		if buf.Bytes()[0] != 0b10000000 {
+			t.Fatalf("invalid bits written, expected: %#08b, got: %#08b", 0b10000000, buf.Bytes()[0])
+		}
+	})
+
+	t.Run("13-bit codes", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		w := NewWriter(buf)
+		w.WriteBitsUint64(0b1010101010101, 13)
+		w.WriteBitsUint64(0b0000000000001, 13)
+		if w.cacheSize != 2 {
+			t.Fatalf("invalid cache size, expected: 2, got: %d", w.cacheSize)
+		}
+		w.Flush()
+		expected := []byte{0b10101010, 0b10101000, 0b00000000, 0b01000000}
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Fatalf("invalid bytes written, expected: %08b, got: %08b", expected, buf.Bytes())
+		}
+	})
+
+	t.Run("WriteBitsUint64 + WriteBit", func(t *testing.T) {
+		// brute force: every offset and length against bit-by-bit writing
+		var value uint64 = 0xf0e1d2c3b4a59687
+		for offset := range 8 {
+			for n := range 65 {
+				buf, expected := &bytes.Buffer{}, &bytes.Buffer{}
+				w, e := NewWriter(buf), NewWriter(expected)
+				for i := range offset {
+					w.WriteBit(byte(i % 2))
+					e.WriteBit(byte(i % 2))
+				}
+				if err := w.WriteBitsUint64(value, byte(n)); err != nil {
+					t.Fatalf("offset %d, n %d: unexpected error: %v", offset, n, err)
+				}
+				for i := n - 1; i >= 0; i-- {
+					e.WriteBit(byte(value >> i & 1))
+				}
+				w.WriteBit(1)
+				e.WriteBit(1)
+				w.Flush()
+				e.Flush()
+				if !bytes.Equal(buf.Bytes(), expected.Bytes()) {
+					t.Fatalf("offset %d, n %d: expected: %08b, got: %08b", offset, n, expected.Bytes(), buf.Bytes())
+				}
+			}
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		w := NewWriter(buf)
+		for n := range 65 {
+			w.WriteBitsUint64(uint64(0x9e3779b97f4a7c15)*uint64(n+1), byte(n))
+		}
+		w.Flush()
+		r := NewReader(buf)
+		for n := range 65 {
+			expected := uint64(0x9e3779b97f4a7c15) * uint64(n+1)
+			if n < 64 {
+				expected &= 1<<n - 1
+			}
+			if res, err := r.ReadBitsUint64(byte(n)); err != nil || res != expected {
+				t.Fatalf("n %d: expected: %#x, got: %#x, %v", n, expected, res, err)
+			}
+		}
+	})
+}
+
+func TestWriterLSBFirst(t *testing.T) {
+	t.Run("WriteBit", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithOrder(buf, LSBFirst)
+		for _, bit := range []byte{0, 1, 0, 1, 0, 0, 1, 0, 1} {
+			w.WriteBit(bit)
+		}
+		w.Flush()
+		expected := []byte{0b01001010, 0b00000001}
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Fatalf("invalid bytes written, expected: %08b, got: %08b", expected, buf.Bytes())
+		}
+	})
+
+	t.Run("WriteBits", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithOrder(buf, LSBFirst)
+		w.WriteBits(0b11111010, 3)
+		w.WriteBits(0b10101001, 8)
+		w.Flush()
+		expected := []byte{0b01001010, 0b00000101}
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Fatalf("invalid bytes written, expected: %08b, got: %08b", expected, buf.Bytes())
+		}
+	})
+
+	t.Run("WriteBitsUint64", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithOrder(buf, LSBFirst)
+		w.WriteBits(1, 4)
+		w.WriteBitsUint64(0xfefcdab896745230, 64)
+		w.WriteBits(0xf, 4)
+		w.Flush()
+		expected := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0xff}
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Fatalf("invalid bytes written, expected: %x, got: %x", expected, buf.Bytes())
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithOrder(buf, LSBFirst)
+		for n := range 65 {
+			w.WriteBitsUint64(uint64(0x9e3779b97f4a7c15)*uint64(n+1), byte(n))
+		}
+		w.WriteByte(0xa5)
+		w.Flush()
+		r := NewReaderWithOrder(buf, LSBFirst)
+		for n := range 65 {
+			expected := uint64(0x9e3779b97f4a7c15) * uint64(n+1)
+			if n < 64 {
+				expected &= 1<<n - 1
+			}
+			if res, err := r.ReadBitsUint64(byte(n)); err != nil || res != expected {
+				t.Fatalf("n %d: expected: %#x, got: %#x, %v", n, expected, res, err)
+			}
+		}
+		if res, err := r.ReadByte(); err != nil || res != 0xa5 {
+			t.Fatalf("invalid byte, expected: %#x, got: %#x, %v", 0xa5, res, err)
+		}
+	})
+}
+
+func TestBitsWritten(t *testing.T) {
+	t.Run("counts every write", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		w := NewWriter(buf)
+		steps := []struct {
+			write  func()
+			bits   int64
+			offset int64
+			bit    byte
+		}{
+			{func() { w.WriteBit(1) }, 1, 0, 1},
+			{func() { w.WriteBits(0xff, 5) }, 6, 0, 6},
+			{func() { w.WriteByte(0xa5) }, 14, 1, 6},
+			{func() { w.WriteBitsUint64(0, 64) }, 78, 9, 6},
+			{func() { w.Align() }, 80, 10, 0},
+			{func() { w.Write([]byte{1, 2, 3}) }, 104, 13, 0},
+			{func() { w.WriteBits(0, 0) }, 104, 13, 0},
+			{func() { w.WriteBits(0, 3) }, 107, 13, 3},
+			{func() { w.Flush() }, 112, 14, 0},
+		}
+		for i, step := range steps {
+			step.write()
+			if w.BitsWritten() != step.bits {
+				t.Fatalf("step %d: invalid bits written, expected: %d, got: %d", i, step.bits, w.BitsWritten())
+			}
+			if offset, bit := w.Offset(); offset != step.offset || bit != step.bit {
+				t.Fatalf("step %d: invalid offset, expected: (%d, %d), got: (%d, %d)", i, step.offset, step.bit, offset, bit)
+			}
+		}
+		if int64(buf.Len())*8 != w.BitsWritten() {
+			t.Fatalf("bits written do not match the output: %d bytes, %d bits", buf.Len(), w.BitsWritten())
+		}
+	})
+
+	t.Run("invalid writes are not counted", func(t *testing.T) {
+		w := NewWriter(&bytes.Buffer{})
+		w.WriteBits(0, 9)
+		w.WriteBitsUint64(0, 65)
+		if w.BitsWritten() != 0 {
+			t.Fatalf("invalid bits written, expected: 0, got: %d", w.BitsWritten())
+		}
+	})
+}
+
+func TestWriterReset(t *testing.T) {
+	t.Run("drops unflushed bits", func(t *testing.T) {
+		first, second := &bytes.Buffer{}, &bytes.Buffer{}
+		w := NewWriterWithOrder(first, LSBFirst)
+		w.WriteBits(0b101, 3)
+		w.WriteByte(0xff)
+		w.Reset(second)
+		if w.BitsWritten() != 0 {
+			t.Fatalf("invalid bits written after reset: %d", w.BitsWritten())
+		}
+		w.WriteBits(0b1, 1)
+		w.WriteBits(0b11, 2)
+		if err := w.Flush(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if first.Len() != 0 {
+			t.Fatalf("expected nothing written before reset, got: %x", first.Bytes())
+		}
+		if !bytes.Equal(second.Bytes(), []byte{0b111}) {
+			t.Fatalf("expected: %x, got: %x", []byte{0b111}, second.Bytes())
+		}
+	})
+
+	t.Run("buffered output is written directly", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		buffered := bufio.NewWriter(buf)
+		w := NewWriter(&bytes.Buffer{})
+		w.Reset(buffered)
+		w.WriteByte(0xa5)
+		w.Flush()
+		buffered.WriteByte(0x5a)
+		buffered.Flush()
+		if !bytes.Equal(buf.Bytes(), []byte{0xa5, 0x5a}) {
+			t.Fatalf("expected: a55a, got: %x", buf.Bytes())
+		}
+	})
+}
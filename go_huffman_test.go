@@ -2,11 +2,19 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"io"
+	"io/fs"
+	"maps"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/serrhiy/go-huffman/benchkit"
+	"github.com/serrhiy/go-huffman/huffman"
 )
 
 type tempFiles struct {
@@ -40,11 +48,11 @@ func encodeDecode(source string) (string, error) {
 	if _, err := bytes.NewBufferString(source).WriteTo(files.infile); err != nil {
 		return "", err
 	}
-	if err := encodeFile(files.infile, files.outfile); err != nil {
+	if err := encodeFile(context.Background(), files.infile, files.outfile); err != nil {
 		return "", err
 	}
 	files.outfile.Seek(0, io.SeekStart)
-	if err := decodeFile(files.outfile, files.resfile); err != nil {
+	if err := decodeFile(context.Background(), files.outfile, files.resfile); err != nil {
 		return "", err
 	}
 	files.resfile.Seek(0, io.SeekStart)
@@ -132,3 +140,144 @@ func BenchmarkGoHuffman(b *testing.B) {
 		})
 	}
 }
+
+func TestRestoreHeader(t *testing.T) {
+	files, err := createTemporaryFiles()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Remove(files.infile.Name())
+	defer os.Remove(files.outfile.Name())
+	defer os.Remove(files.resfile.Name())
+
+	modTime := time.Unix(1600000000, 0)
+	if _, err := files.infile.WriteString("metadata"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := files.infile.Chmod(0640); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Chtimes(files.infile.Name(), modTime, modTime); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := encodeFile(context.Background(), files.infile, files.outfile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	files.outfile.Seek(0, io.SeekStart)
+	header, err := huffman.ReadHeader(files.outfile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if header == nil || header.Name != filepath.Base(files.infile.Name()) {
+		t.Fatalf("invalid header: %+v", header)
+	}
+	files.outfile.Seek(0, io.SeekStart)
+	if err := decodeFile(context.Background(), files.outfile, files.resfile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	info, err := os.Stat(files.resfile.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Fatalf("expected mode %v, got %v", fs.FileMode(0640), info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Fatalf("expected mtime %v, got %v", modTime, info.ModTime())
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	source := "abbccccdddddddd"
+
+	t.Run("dot from input", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		if err := writeTree(bytes.NewBufferString(source), true, "dot", out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.HasPrefix(out.Bytes(), []byte("digraph huffman {")) {
+			t.Fatalf("unexpected output:\n%s", out.String())
+		}
+	})
+
+	t.Run("json from encoded", func(t *testing.T) {
+		encoded := &bytes.Buffer{}
+		encoder := huffman.NewEncoder(bytes.NewReader([]byte(source)), encoded)
+		if err := encoder.Encode(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		out := &bytes.Buffer{}
+		if err := writeTree(encoded, false, "json", out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var tree map[string]any
+		if err := json.Unmarshal(out.Bytes(), &tree); err != nil {
+			t.Fatalf("invalid json: %v", err)
+		}
+		if _, ok := tree["left"]; !ok {
+			t.Fatalf("unexpected tree: %s", out.String())
+		}
+	})
+
+	t.Run("same tree as the encoder", func(t *testing.T) {
+		input := "the quick brown fox jumps over the lazy dog"
+		encoded := &bytes.Buffer{}
+		if err := huffman.NewEncoder(strings.NewReader(input), encoded).Encode(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		codes := func(in io.Reader, fromInput bool) map[byte]string {
+			out := &bytes.Buffer{}
+			if err := writeTree(in, fromInput, "json", out); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			type jsonNode struct {
+				Symbol *byte     `json:"symbol"`
+				Code   string    `json:"code"`
+				Left   *jsonNode `json:"left"`
+				Right  *jsonNode `json:"right"`
+			}
+			var root jsonNode
+			if err := json.Unmarshal(out.Bytes(), &root); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			result := map[byte]string{}
+			var walk func(node *jsonNode)
+			walk = func(node *jsonNode) {
+				if node == nil {
+					return
+				}
+				if node.Symbol != nil {
+					result[*node.Symbol] = node.Code
+				}
+				walk(node.Left)
+				walk(node.Right)
+			}
+			walk(&root)
+			return result
+		}
+		fromInput, fromEncoded := codes(strings.NewReader(input), true), codes(encoded, false)
+		if !maps.Equal(fromInput, fromEncoded) {
+			t.Fatalf("trees differ, input: %v, encoded: %v", fromInput, fromEncoded)
+		}
+	})
+
+	t.Run("other modes", func(t *testing.T) {
+		*lz77 = true
+		defer func() { *lz77 = false }()
+		if err := writeTree(bytes.NewBufferString(source), true, "dot", &bytes.Buffer{}); err == nil {
+			t.Fatal("expected error with -lz")
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		if err := writeTree(bytes.NewBufferString(source), true, "svg", &bytes.Buffer{}); err == nil {
+			t.Fatal("expected error for unknown format")
+		}
+	})
+
+	t.Run("invalid encoded input", func(t *testing.T) {
+		if err := writeTree(&bytes.Buffer{}, false, "dot", &bytes.Buffer{}); err == nil {
+			t.Fatal("expected error for empty encoded input")
+		}
+	})
+}
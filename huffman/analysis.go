@@ -0,0 +1,115 @@
+package huffman
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+)
+
+type SymbolStats struct {
+	Symbol      byte    `json:"symbol"`
+	Count       uint    `json:"count"`
+	Probability float64 `json:"probability"`
+	CodeLength  int     `json:"code_length"`
+	Code        string  `json:"code"`
+}
+
+type Analysis struct {
+	Size uint64 `json:"size"`
+	// Entropy and AverageCodeLength are measured in bits per symbol.
+	Entropy           float64 `json:"entropy"`
+	AverageCodeLength float64 `json:"average_code_length"`
+	Redundancy        float64 `json:"redundancy"`
+	// TheoreticalSize is the smallest possible size in bytes of an order-0
+	// encoding, EncodedSize is the size of the .hfm stream the encoder
+	// writes, with the file header given to AnalyzeWithHeader.
+	TheoreticalSize uint64        `json:"theoretical_size"`
+	TreeSize        uint16        `json:"tree_size_bits"`
+	ContentSize     uint64        `json:"content_size_bits"`
+	EncodedSize     uint64        `json:"encoded_size"`
+	Symbols         []SymbolStats `json:"symbols"`
+}
+
+func bitsToBytes(bits uint64) uint64 {
+	return (bits + 7) / 8
+}
+
+func uvarintSize(value uint64) uint64 {
+	return uint64(len(binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64), value)))
+}
+
+// calculateEncodedSize returns the size of a plain Huffman stream as written
+// by the encoder: the headerless layout has fixed-size fields, a stream with
+// a file header varints.
+func calculateEncodedSize(header *Header, treeSize uint16, contentSize uint64) (uint64, error) {
+	if header == nil {
+		return 2 + bitsToBytes(uint64(treeSize)) + 8 + bitsToBytes(contentSize), nil
+	}
+	size, err := header.size()
+	if err != nil {
+		return 0, err
+	}
+	size += uvarintSize(uint64(treeSize)) + bitsToBytes(uint64(treeSize))
+	return size + uvarintSize(contentSize) + bitsToBytes(contentSize), nil
+}
+
+// Analyze analyses the input as encoded by NewEncoder, without a file header.
+func Analyze(reader io.Reader) (*Analysis, error) {
+	return AnalyzeWithHeader(reader, nil)
+}
+
+// AnalyzeWithHeader analyses the input as encoded with the given file
+// header, as the command line tool does.
+func AnalyzeWithHeader(reader io.Reader, header *Header) (*Analysis, error) {
+	frequencies, err := getFrequencyMap(reader)
+	if err != nil {
+		return nil, err
+	}
+	root := buildTree(frequencies)
+	codes := buildCodes(root)
+	contentSize, err := calculateContentSize(codes, frequencies)
+	if err != nil {
+		return nil, err
+	}
+	analysis := &Analysis{
+		TreeSize:    calculateTreeSize(root),
+		ContentSize: contentSize,
+		Symbols:     make([]SymbolStats, 0, len(frequencies)),
+	}
+	for _, count := range frequencies {
+		analysis.Size += uint64(count)
+	}
+	analysis.EncodedSize, err = calculateEncodedSize(header, analysis.TreeSize, contentSize)
+	if err != nil {
+		return nil, err
+	}
+	if analysis.Size == 0 {
+		return analysis, nil
+	}
+
+	for char, count := range frequencies {
+		probability := float64(count) / float64(analysis.Size)
+		analysis.Entropy -= probability * math.Log2(probability)
+		analysis.Symbols = append(analysis.Symbols, SymbolStats{
+			Symbol:      char,
+			Count:       count,
+			Probability: probability,
+			CodeLength:  codes[char].Len(),
+			Code:        codes[char].String(),
+		})
+	}
+	slices.SortFunc(analysis.Symbols, func(a, b SymbolStats) int {
+		if a.Count != b.Count {
+			if a.Count > b.Count {
+				return -1
+			}
+			return 1
+		}
+		return int(a.Symbol) - int(b.Symbol)
+	})
+	analysis.AverageCodeLength = float64(contentSize) / float64(analysis.Size)
+	analysis.Redundancy = analysis.AverageCodeLength - analysis.Entropy
+	analysis.TheoreticalSize = uint64(math.Ceil(analysis.Entropy * float64(analysis.Size) / 8))
+	return analysis, nil
+}
@@ -0,0 +1,100 @@
+package huffman
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/serrhiy/go-huffman/benchkit"
+)
+
+func TestAnalyze(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		analysis, err := Analyze(&bytes.Buffer{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if analysis.Size != 0 || analysis.Entropy != 0 || len(analysis.Symbols) != 0 {
+			t.Fatalf("invalid analysis of empty input: %+v", analysis)
+		}
+		if analysis.EncodedSize != 10 {
+			t.Fatalf("invalid encoded size, expected: %d, got: %d", 10, analysis.EncodedSize)
+		}
+	})
+
+	t.Run("error propagation", func(t *testing.T) {
+		reader := &errorReader{limit: 1, reader: bytes.NewBufferString("abc")}
+		if _, err := Analyze(reader); err == nil {
+			t.Fatal("expected error, got: <nil>")
+		}
+	})
+
+	t.Run("uniform", func(t *testing.T) {
+		analysis, err := Analyze(bytes.NewBufferString("abcdabcd"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if analysis.Entropy != 2 || analysis.AverageCodeLength != 2 || analysis.Redundancy != 0 {
+			t.Fatalf("invalid analysis: %+v", analysis)
+		}
+		if analysis.TheoreticalSize != 2 {
+			t.Fatalf("invalid theoretical size, expected: %d, got: %d", 2, analysis.TheoreticalSize)
+		}
+		if len(analysis.Symbols) != 4 || analysis.Symbols[0].Symbol != 'a' || analysis.Symbols[3].Symbol != 'd' {
+			t.Fatalf("invalid symbols: %+v", analysis.Symbols)
+		}
+	})
+
+	t.Run("skewed", func(t *testing.T) {
+		analysis, err := Analyze(bytes.NewBufferString("aaaaaaab"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := -(7.0/8*math.Log2(7.0/8) + 1.0/8*math.Log2(1.0/8))
+		if math.Abs(analysis.Entropy-expected) > 1e-12 {
+			t.Fatalf("invalid entropy, expected: %f, got: %f", expected, analysis.Entropy)
+		}
+		if analysis.Redundancy <= 0 {
+			t.Fatalf("expected positive redundancy, got: %f", analysis.Redundancy)
+		}
+		first := analysis.Symbols[0]
+		if first.Symbol != 'a' || first.Count != 7 || first.CodeLength != 1 {
+			t.Fatalf("invalid most frequent symbol: %+v", first)
+		}
+	})
+
+	t.Run("encoded size", func(t *testing.T) {
+		inputs := []string{"a", "hello world", benchkit.Text(10_000), benchkit.Random(10_000)}
+		for _, input := range inputs {
+			analysis, err := Analyze(bytes.NewBufferString(input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			writer := &bytes.Buffer{}
+			if err := NewEncoder(bytes.NewReader([]byte(input)), writer).Encode(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if analysis.EncodedSize != uint64(writer.Len()) {
+				t.Fatalf("invalid encoded size, expected: %d, got: %d", writer.Len(), analysis.EncodedSize)
+			}
+
+			header := &Header{Name: "input.txt", Mode: 0644, ModTime: time.Unix(1700000000, 0)}
+			analysis, err = AnalyzeWithHeader(bytes.NewBufferString(input), header)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			writer.Reset()
+			options := EncoderOptions{Header: header}
+			if err := NewEncoderWithOptions(bytes.NewReader([]byte(input)), writer, options).Encode(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if analysis.EncodedSize != uint64(writer.Len()) {
+				t.Fatalf("invalid encoded size with header, expected: %d, got: %d", writer.Len(), analysis.EncodedSize)
+			}
+			if analysis.AverageCodeLength < analysis.Entropy || analysis.AverageCodeLength > analysis.Entropy+1 {
+				t.Fatalf("average code length %f out of entropy bounds %f", analysis.AverageCodeLength, analysis.Entropy)
+			}
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+)
+
+// sliceBitWriter appends bits to buffer starting from the most significant
+// bit of every byte, like bitio.Writer.
+type sliceBitWriter struct {
+	buffer    []byte
+	cache     uint64
+	cacheSize byte
+}
+
+func (writer *sliceBitWriter) writeBits(bits uint64, n byte) {
+	if n > 56 {
+		writer.writeBits(bits>>32, n-32)
+		n = 32
+	}
+	bits &= 1<<n - 1
+	writer.cache |= bits << (64 - writer.cacheSize - n)
+	writer.cacheSize += n
+	for writer.cacheSize >= 8 {
+		writer.buffer = append(writer.buffer, byte(writer.cache>>56))
+		writer.cache <<= 8
+		writer.cacheSize -= 8
+	}
+}
+
+func (writer *sliceBitWriter) WriteBit(bit byte) error {
+	writer.writeBits(uint64(bit), 1)
+	return nil
+}
+
+func (writer *sliceBitWriter) WriteByte(b byte) error {
+	writer.writeBits(uint64(b), 8)
+	return nil
+}
+
+// align pads the last byte with zero bits.
+func (writer *sliceBitWriter) align() {
+	if writer.cacheSize > 0 {
+		writer.buffer = append(writer.buffer, byte(writer.cache>>56))
+		writer.cache, writer.cacheSize = 0, 0
+	}
+}
+
+// sliceBitReader reads the bits of data starting from the most significant
+// bit of every byte, like bitio.Reader. position counts bits.
+type sliceBitReader struct {
+	data     []byte
+	position int
+}
+
+func (reader *sliceBitReader) ReadBit() (byte, error) {
+	if reader.position >= len(reader.data)*8 {
+		return 0, io.EOF
+	}
+	bit := reader.data[reader.position>>3] >> (7 - reader.position&7) & 1
+	reader.position += 1
+	return bit, nil
+}
+
+func (reader *sliceBitReader) ReadByte() (byte, error) {
+	if reader.position+8 > len(reader.data)*8 {
+		return 0, io.EOF
+	}
+	index, shift := reader.position>>3, reader.position&7
+	b := reader.data[index] << shift
+	if shift > 0 {
+		b |= reader.data[index+1] >> (8 - shift)
+	}
+	reader.position += 8
+	return b, nil
+}
+
+// offset returns the number of bytes the reader started, including a
+// partially read one.
+func (reader *sliceBitReader) offset() int {
+	return (reader.position + 7) / 8
+}
+
+// appendWriter appends everything written to it to buffer.
+type appendWriter struct {
+	buffer []byte
+}
+
+func (writer *appendWriter) Write(b []byte) (int, error) {
+	writer.buffer = append(writer.buffer, b...)
+	return len(b), nil
+}
+
+// appendGrowLimit caps the space AppendDecode reserves up front, so a
+// stream claiming a huge content length cannot make it allocate more than
+// that before decoding a single byte.
+const appendGrowLimit = 64 << 20
+
+func minLeafDepth(root *node) uint64 {
+	if root == nil {
+		return math.MaxUint64
+	}
+	if root.isLeaf() {
+		return 0
+	}
+	return 1 + min(minLeafDepth(root.left), minLeafDepth(root.right))
+}
+
+// AppendEncode appends src encoded like NewEncoder without options to dst
+// and returns the extended slice. It works on the slices directly: dst is
+// grown once to the exact size of the output and no buffered readers or
+// writers are involved.
+func AppendEncode(dst, src []byte) ([]byte, error) {
+	var frequencies [256]uint
+	for _, b := range src {
+		frequencies[b] += 1
+	}
+	root := buildSymbolTree(frequencies[:])
+	var codes [256]codeWord
+	if !codeWords(root, 0, 0, &codes) {
+		// needs gigantic inputs, the streaming encoder handles any length
+		output := &appendWriter{dst}
+		if err := NewEncoder(bytes.NewReader(src), output).Encode(); err != nil {
+			return dst, err
+		}
+		return output.buffer, nil
+	}
+	treeSize := calculateTreeSize(root)
+	var length uint64 = 0
+	for b, count := range frequencies {
+		length += uint64(count) * uint64(codes[b].length)
+	}
+
+	dst = slices.Grow(dst, 2+(int(treeSize)+7)/8+8+int((length+7)/8))
+	writer := &sliceBitWriter{binary.LittleEndian.AppendUint16(dst, treeSize), 0, 0}
+	writeCodes(root, writer)
+	writer.align()
+	writer.buffer = binary.LittleEndian.AppendUint64(writer.buffer, length)
+	for _, b := range src {
+		writer.writeBits(codes[b].bits, codes[b].length)
+	}
+	writer.align()
+	return writer.buffer, nil
+}
+
+// AppendDecode appends the decoded content of src to dst and returns the
+// extended slice. Streams of the plain Huffman method, with or without a
+// file header, are decoded straight from src into dst. dst is grown once by
+// an upper bound of the output size, the content length divided by the
+// shortest code length, unless that exceeds 64 MiB; larger outputs grow dst
+// further while decoding. Other methods go through a decoder. On error dst
+// is returned unchanged.
+func AppendDecode(dst, src []byte) ([]byte, error) {
+	version := byte(legacyFormatVersion)
+	if len(src) >= len(magic) && src[0] == magic[0] && src[1] == magic[1] {
+		reader := bytes.NewReader(src)
+		_, method, headerVersion, err := readFileHeader(reader)
+		if err != nil {
+			return dst, err
+		}
+		if method != methodHuffman {
+			output := &appendWriter{dst}
+			if err := NewDecoder(bytes.NewReader(src), output).Decode(); err != nil {
+				return dst, err
+			}
+			return output.buffer, nil
+		}
+		src, version = src[len(src)-reader.Len():], headerVersion
+	}
+
+	var treeSize, length uint64
+	var n int
+	if version > legacyFormatVersion {
+		if treeSize, n = binary.Uvarint(src); n <= 0 || treeSize > math.MaxUint16 {
+			return dst, ErrInvalidStructure
+		}
+	} else if len(src) >= 2 {
+		treeSize, n = uint64(binary.LittleEndian.Uint16(src)), 2
+	} else {
+		return dst, ErrInvalidStructure
+	}
+	reader := &sliceBitReader{src[n:], 0}
+	root, err := _readTree(reader, uint16(treeSize), &nodeArena{})
+	if err != nil {
+		return dst, ErrInvalidStructure
+	}
+	src, n = src[n+reader.offset():], 0
+	if version > legacyFormatVersion {
+		length, n = binary.Uvarint(src)
+	} else if len(src) >= 8 {
+		length, n = binary.LittleEndian.Uint64(src), 8
+	}
+	if n <= 0 {
+		return dst, ErrInvalidStructure
+	}
+	content := src[n:]
+	if length > uint64(len(content))*8 || root == nil && length != 0 {
+		return dst, ErrInvalidStructure
+	}
+	if length == 0 {
+		return dst, nil
+	}
+
+	// every code takes at least the depth of the shallowest leaf
+	output := slices.Grow(dst, int(min(length/max(minLeafDepth(root), 1), appendGrowLimit)))
+	current := root
+	for position := range length {
+		if content[position>>3]>>(7-position&7)&1 == 1 {
+			current = current.left
+		} else {
+			current = current.right
+		}
+		if current == nil {
+			return dst, ErrInvalidStructure
+		}
+		if current.isLeaf() {
+			output = append(output, byte(current.char))
+			current = root
+		}
+	}
+	return output, nil
+}
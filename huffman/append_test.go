@@ -0,0 +1,185 @@
+package huffman
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/serrhiy/go-huffman/benchkit"
+)
+
+var appendInputs = []string{
+	"",
+	"a",
+	"aaaaaaaaaaaaaaaaaaaaaaa",
+	"Hello world!",
+	string([]byte{0, 234, 14, 45, 13, 78, 32, 14}),
+	strings.Repeat("abcabcabd", 300),
+	string(bytes.Repeat([]byte{0, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 3, 3, 3, 3}, 40)),
+}
+
+func encodeStream(t testing.TB, input string, options EncoderOptions) []byte {
+	output := &bytes.Buffer{}
+	if err := NewEncoderWithOptions(strings.NewReader(input), output, options).Encode(); err != nil {
+		t.Fatalf("unexpected error while encoding: %v", err)
+	}
+	return output.Bytes()
+}
+
+func TestAppendEncode(t *testing.T) {
+	t.Run("same output as the encoder", func(t *testing.T) {
+		all := make([]byte, 256)
+		for i := range all {
+			all[i] = byte(i)
+		}
+		for _, input := range append(appendInputs, string(all)) {
+			expected := encodeStream(t, input, EncoderOptions{})
+			result, err := AppendEncode(nil, []byte(input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(result, expected) {
+				t.Fatalf("input %q: expected: %x, got: %x", input, expected, result)
+			}
+		}
+	})
+
+	t.Run("appends to dst", func(t *testing.T) {
+		prefix := []byte("prefix")
+		result, err := AppendEncode(prefix, []byte("Hello world!"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.HasPrefix(result, prefix) || !bytes.Equal(result[len(prefix):], encodeStream(t, "Hello world!", EncoderOptions{})) {
+			t.Fatalf("invalid output: %x", result)
+		}
+	})
+}
+
+func TestAppendDecode(t *testing.T) {
+	t.Run("every format", func(t *testing.T) {
+		formats := map[string]EncoderOptions{
+			"headerless": {},
+			"header":     {Header: &Header{Name: "input.txt"}},
+			"static":     {Frequencies: map[byte]uint{'a': 1, 'b': 2}},
+			"lz77":       {Level: 6},
+		}
+		for name, options := range formats {
+			for _, input := range appendInputs {
+				if options.Frequencies != nil && strings.Trim(input, "ab") != "" {
+					continue
+				}
+				result, err := AppendDecode([]byte("prefix"), encodeStream(t, input, options))
+				if err != nil {
+					t.Fatalf("%s, input %q: unexpected error: %v", name, input, err)
+				}
+				if string(result) != "prefix"+input {
+					t.Fatalf("%s: expected: %q, got: %q", name, "prefix"+input, result)
+				}
+			}
+		}
+	})
+
+	t.Run("preallocation", func(t *testing.T) {
+		// the output is allocated once however skewed the input is
+		skewed := strings.Repeat("a", 100000) + benchkit.Range(0, 256)
+		uniform := strings.Repeat(benchkit.Range(0, 256), 400)
+		allocations := map[string]float64{}
+		for name, input := range map[string]string{"skewed": skewed, "uniform": uniform} {
+			encoded := encodeStream(t, input, EncoderOptions{})
+			result, err := AppendDecode(nil, encoded)
+			if err != nil || string(result) != input {
+				t.Fatalf("%s: unexpected result: %v", name, err)
+			}
+			allocations[name] = testing.AllocsPerRun(10, func() { AppendDecode(nil, encoded) })
+		}
+		if allocations["skewed"] != allocations["uniform"] {
+			t.Fatalf("expected the same allocations, got: %v", allocations)
+		}
+	})
+
+	t.Run("legacy header version", func(t *testing.T) {
+		encoded := encodeStream(t, "Hello world!", EncoderOptions{})
+		stream := append([]byte{'H', 'F', legacyFormatVersion, methodHuffman, 0}, encoded...)
+		result, err := AppendDecode(nil, stream)
+		if err != nil || string(result) != "Hello world!" {
+			t.Fatalf("expected: %q, got: %q, %v", "Hello world!", result, err)
+		}
+	})
+
+	t.Run("invalid streams", func(t *testing.T) {
+		encoded := encodeStream(t, "Hello world!", EncoderOptions{})
+		streams := [][]byte{
+			{},
+			{1},
+			encoded[:2],
+			encoded[:len(encoded)-1],
+			{'H', 'F'},
+			{'H', 'F', formatVersion, methodHuffman, 0},
+			{'H', 'F', formatVersion, methodHuffman, 0, 0xff, 0xff, 0xff, 0xff},
+		}
+		dst := []byte("prefix")
+		for _, stream := range streams {
+			result, err := AppendDecode(dst, stream)
+			if err == nil {
+				t.Fatalf("stream %x: expected an error", stream)
+			}
+			if !bytes.Equal(result, dst) {
+				t.Fatalf("stream %x: dst changed: %q", stream, result)
+			}
+		}
+	})
+}
+
+func FuzzAppendDecode(f *testing.F) {
+	for _, input := range appendInputs {
+		f.Add(encodeStream(f, input, EncoderOptions{}))
+		f.Add(encodeStream(f, input, EncoderOptions{Header: &Header{}}))
+	}
+	f.Add([]byte{0x00, 0x00, 0x01})
+	f.Add([]byte{0x09, 0x00, 0x80})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		output := &bytes.Buffer{}
+		streamErr := NewDecoder(bytes.NewReader(data), output).Decode()
+		result, err := AppendDecode(nil, data)
+		if (err == nil) != (streamErr == nil) {
+			t.Fatalf("AppendDecode error: %v, decoder error: %v", err, streamErr)
+		}
+		if err == nil && !bytes.Equal(result, output.Bytes()) {
+			t.Fatalf("AppendDecode output: %x, decoder output: %x", result, output.Bytes())
+		}
+	})
+}
+
+func BenchmarkAppend(b *testing.B) {
+	input := []byte(resetMessages[3])
+	encoded := encodeStream(b, string(input), EncoderOptions{})
+
+	b.Run("encode", func(b *testing.B) {
+		b.ReportAllocs()
+		var dst []byte
+		for b.Loop() {
+			dst, _ = AppendEncode(dst[:0], input)
+		}
+	})
+	b.Run("decode", func(b *testing.B) {
+		b.ReportAllocs()
+		var dst []byte
+		for b.Loop() {
+			dst, _ = AppendDecode(dst[:0], encoded)
+		}
+	})
+	b.Run("stream encode", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			NewEncoder(bytes.NewReader(input), &bytes.Buffer{}).Encode()
+		}
+	})
+	b.Run("stream decode", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			NewDecoder(bytes.NewReader(encoded), &bytes.Buffer{}).Decode()
+		}
+	})
+}
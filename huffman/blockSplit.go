@@ -0,0 +1,96 @@
+package huffman
+
+// Block splitting cuts the tokens of a chunk of input into segments and
+// merges neighbouring segments while coding them with one pair of tables is
+// cheaper than paying for the header of a new block. Costs are the sizes of
+// dynamic blocks in bits: the coded tables plus the coded content.
+const splitSegmentSize = 1 << 12
+
+type tokenStats struct {
+	literals  []uint
+	distances []uint
+}
+
+func newTokenStats(tokens []lzToken) tokenStats {
+	literals, distances := tokenFrequencies(tokens)
+	return tokenStats{literals, distances}
+}
+
+func (stats tokenStats) merge(other tokenStats) tokenStats {
+	result := tokenStats{make([]uint, len(stats.literals)), make([]uint, len(stats.distances))}
+	for symbol := range stats.literals {
+		result.literals[symbol] = stats.literals[symbol] + other.literals[symbol]
+	}
+	for symbol := range stats.distances {
+		result.distances[symbol] = stats.distances[symbol] + other.distances[symbol]
+	}
+	result.literals[endOfBlock] = 1
+	return result
+}
+
+func (stats tokenStats) cost() (int, error) {
+	encoding, err := dynamicEncoding(stats.literals, stats.distances)
+	if err != nil {
+		return 0, err
+	}
+	return encoding.size(stats.literals, stats.distances), nil
+}
+
+// splitTokens returns the number of tokens in each block.
+func splitTokens(tokens []lzToken) ([]int, error) {
+	end := min(splitSegmentSize, len(tokens))
+	current := newTokenStats(tokens[:end])
+	currentCost, err := current.cost()
+	if err != nil {
+		return nil, err
+	}
+	var sizes []int
+	start := 0
+	for end < len(tokens) {
+		next := min(end+splitSegmentSize, len(tokens))
+		segment := newTokenStats(tokens[end:next])
+		segmentCost, err := segment.cost()
+		if err != nil {
+			return nil, err
+		}
+		merged := current.merge(segment)
+		mergedCost, err := merged.cost()
+		if err != nil {
+			return nil, err
+		}
+		if mergedCost <= currentCost+segmentCost {
+			current, currentCost = merged, mergedCost
+		} else {
+			sizes = append(sizes, end-start)
+			start, current, currentCost = end, segment, segmentCost
+		}
+		end = next
+	}
+	return append(sizes, end-start), nil
+}
+
+func tokensSize(tokens []lzToken) int {
+	size := 0
+	for _, token := range tokens {
+		size += max(1, int(token.length))
+	}
+	return size
+}
+
+// writeSplitBlocks writes tokens, produced from data, as the blocks chosen
+// by splitTokens.
+func (writer *deflateWriter) writeSplitBlocks(tokens []lzToken, data []byte, final bool, level compressionLevel) error {
+	sizes, err := splitTokens(tokens)
+	if err != nil {
+		return err
+	}
+	for i, size := range sizes {
+		dataSize := tokensSize(tokens[:size])
+		last := final && i == len(sizes)-1
+		if err := writer.writeBlock(tokens[:size], data[:dataSize], last, level); err != nil {
+			return err
+		}
+		tokens, data = tokens[size:], data[dataSize:]
+	}
+	return nil
+}
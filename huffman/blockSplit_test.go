@@ -0,0 +1,109 @@
+package huffman
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/serrhiy/go-huffman/benchkit"
+)
+
+func TestSplitTokens(t *testing.T) {
+	t.Run("uniform", func(t *testing.T) {
+		tokens := literalTokens([]byte(benchkit.Text(100000)), nil)
+		sizes, err := splitTokens(tokens)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(sizes) != 1 || sizes[0] != len(tokens) {
+			t.Fatalf("expected a single block, got sizes: %v", sizes)
+		}
+	})
+
+	t.Run("distribution change", func(t *testing.T) {
+		text := benchkit.Text(10 * splitSegmentSize)
+		data := []byte(text + benchkit.Random(10*splitSegmentSize) + text)
+		tokens := literalTokens(data, nil)
+		sizes, err := splitTokens(tokens)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := []int{10 * splitSegmentSize, 10 * splitSegmentSize, 10 * splitSegmentSize}
+		if !slices.Equal(sizes, expected) {
+			t.Fatalf("expected sizes %v, got: %v", expected, sizes)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		sizes, err := splitTokens(nil)
+		if err != nil || len(sizes) != 1 || sizes[0] != 0 {
+			t.Fatalf("expected one empty block, got: %v, err: %v", sizes, err)
+		}
+	})
+}
+
+func TestSplitBlocks(t *testing.T) {
+	text := benchkit.Text(100000)
+	source := []byte(text + benchkit.Range(0, 256) + benchkit.Random(100000) + text)
+	for _, options := range []EncoderOptions{
+		{SplitBlocks: true},
+		{SplitBlocks: true, RawDeflate: true},
+		{SplitBlocks: true, LZ77: &LZ77Options{}},
+		{Level: 9},
+	} {
+		encoded := encodeStatic(t, source, options)
+		writer := &bytes.Buffer{}
+		decoder := NewDecoderWithOptions(bytes.NewReader(encoded), writer, DecoderOptions{RawDeflate: options.RawDeflate})
+		if err := decoder.Decode(); err != nil {
+			t.Fatalf("%+v: unexpected error: %v", options, err)
+		}
+		if !bytes.Equal(writer.Bytes(), source) {
+			t.Fatalf("%+v: invalid decoded content", options)
+		}
+	}
+
+	t.Run("smaller", func(t *testing.T) {
+		split := encodeStatic(t, source, EncoderOptions{SplitBlocks: true, RawDeflate: true})
+		whole := encodeStatic(t, source, EncoderOptions{RawDeflate: true})
+		if len(split) >= len(whole) {
+			t.Fatalf("expected split output smaller than %d bytes, got: %d", len(whole), len(split))
+		}
+		decoded, err := io.ReadAll(flate.NewReader(bytes.NewReader(split)))
+		if err != nil || !bytes.Equal(decoded, source) {
+			t.Fatalf("compress/flate failed to decode the stream: %v", err)
+		}
+	})
+
+	t.Run("plain huffman", func(t *testing.T) {
+		split := encodeStatic(t, source, EncoderOptions{SplitBlocks: true})
+		if method := split[3]; method != methodLZ77Reuse {
+			t.Fatalf("expected method %d, got: %d", methodLZ77Reuse, method)
+		}
+		whole := &bytes.Buffer{}
+		if err := NewEncoder(bytes.NewReader(source), whole).Encode(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(split) >= whole.Len() {
+			t.Fatalf("expected split output smaller than %d bytes, got: %d", whole.Len(), len(split))
+		}
+	})
+
+	t.Run("fixed tables", func(t *testing.T) {
+		split := encodeStatic(t, source, EncoderOptions{SplitBlocks: true, Level: 1})
+		whole := encodeStatic(t, source, EncoderOptions{Level: 1})
+		if !bytes.Equal(split, whole) {
+			t.Fatalf("expected the output of level 1 to be unchanged")
+		}
+	})
+
+	t.Run("frequencies", func(t *testing.T) {
+		options := EncoderOptions{Frequencies: englishFrequencies(), SharedTable: true}
+		whole := encodeStatic(t, source, options)
+		options.SplitBlocks = true
+		if split := encodeStatic(t, source, options); !bytes.Equal(split, whole) {
+			t.Fatalf("expected the output with frequencies to be unchanged")
+		}
+	})
+}
@@ -0,0 +1,328 @@
+package huffman
+
+import (
+	"cmp"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/serrhiy/go-huffman/bitio"
+)
+
+const maxCodeLength = 63
+const maxAlphabetSize = math.MaxUint16
+
+var ErrUnknownSymbol = errors.New("symbol has no code")
+
+// CodeTable is a canonical Huffman code over the symbols 0..AlphabetSize()-1.
+// Symbols with zero code length are absent from the code.
+type CodeTable struct {
+	lengths   []uint8
+	codes     []uint64
+	counts    [maxCodeLength + 1]int
+	symbols   []uint16
+	maxLength int
+}
+
+type codeEntry struct {
+	Symbol uint16 `json:"symbol"`
+	Length uint8  `json:"length"`
+	Code   string `json:"code"`
+}
+
+type codeTableJSON struct {
+	AlphabetSize int         `json:"alphabet_size"`
+	Codes        []codeEntry `json:"codes"`
+}
+
+// NewCodeTable builds the code from symbol frequencies indexed by symbol.
+func NewCodeTable(frequencies []uint) (*CodeTable, error) {
+	if len(frequencies) > maxAlphabetSize {
+		return nil, fmt.Errorf("alphabet is too large: %d", len(frequencies))
+	}
+	root := buildSymbolTree(frequencies)
+	lengths := make([]uint8, len(frequencies))
+	for symbol, length := range codeLengths(root, len(frequencies)) {
+		if length > maxCodeLength {
+			return nil, fmt.Errorf("code length %d exceeds %d bits", length, maxCodeLength)
+		}
+		lengths[symbol] = uint8(length)
+	}
+	return NewCodeTableFromLengths(lengths)
+}
+
+// NewLimitedCodeTable builds the code from symbol frequencies like
+// NewCodeTable, but no code is longer than maxLength bits. Formats such as
+// DEFLATE need this; the code may then be slightly less than optimal.
+func NewLimitedCodeTable(frequencies []uint, maxLength int) (*CodeTable, error) {
+	if maxLength < 1 || maxLength > maxCodeLength {
+		return nil, fmt.Errorf("invalid maximum code length: %d", maxLength)
+	}
+	if len(frequencies) > maxAlphabetSize {
+		return nil, fmt.Errorf("alphabet is too large: %d", len(frequencies))
+	}
+	lengths, err := limitedCodeLengths(frequencies, maxLength)
+	if err != nil {
+		return nil, err
+	}
+	return NewCodeTableFromLengths(lengths)
+}
+
+func limitedCodeLengths(frequencies []uint, maxLength int) ([]uint8, error) {
+	lengths := codeLengths(buildSymbolTree(frequencies), len(frequencies))
+	symbols := make([]int, 0, len(frequencies))
+	counts := make([]int, maxLength+1)
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		symbols = append(symbols, symbol)
+		for len(counts) <= length {
+			counts = append(counts, 0)
+		}
+		counts[length] += 1
+	}
+	if uint64(len(symbols)) > uint64(1)<<maxLength {
+		return nil, fmt.Errorf("%d symbols do not fit into %d-bit codes", len(symbols), maxLength)
+	}
+
+	// Move leaves deeper than maxLength up the tree keeping the code
+	// complete, see ITU T.81 (JPEG), Annex K.3.
+	for length := len(counts) - 1; length > maxLength; length-- {
+		for counts[length] > 0 {
+			shorter := length - 2
+			for counts[shorter] == 0 {
+				shorter -= 1
+			}
+			counts[length] -= 2
+			counts[length-1] += 1
+			counts[shorter+1] += 2
+			counts[shorter] -= 1
+		}
+	}
+
+	// the most frequent symbols keep the shortest codes
+	slices.SortStableFunc(symbols, func(a, b int) int {
+		return cmp.Compare(lengths[a], lengths[b])
+	})
+	result := make([]uint8, len(frequencies))
+	index := 0
+	for length := 1; length <= maxLength; length++ {
+		for range counts[length] {
+			result[symbols[index]] = uint8(length)
+			index += 1
+		}
+	}
+	return result, nil
+}
+
+// NewCodeTableFromLengths assigns canonical codes to the given code lengths.
+// Incomplete codes are accepted, over-subscribed ones are not.
+func NewCodeTableFromLengths(lengths []uint8) (*CodeTable, error) {
+	if len(lengths) > maxAlphabetSize {
+		return nil, fmt.Errorf("alphabet is too large: %d", len(lengths))
+	}
+	table := &CodeTable{
+		lengths: slices.Clone(lengths),
+		codes:   make([]uint64, len(lengths)),
+	}
+	for symbol, length := range lengths {
+		if length > maxCodeLength {
+			return nil, fmt.Errorf("invalid code length %d for symbol %d", length, symbol)
+		}
+		if length > 0 {
+			table.counts[length] += 1
+			table.symbols = append(table.symbols, uint16(symbol))
+			table.maxLength = max(table.maxLength, int(length))
+		}
+	}
+	slices.SortStableFunc(table.symbols, func(a, b uint16) int {
+		return int(lengths[a]) - int(lengths[b])
+	})
+
+	var next [maxCodeLength + 1]uint64
+	var code uint64 = 0
+	for length := 1; length <= table.maxLength; length++ {
+		code = (code + uint64(table.counts[length-1])) << 1
+		if code+uint64(table.counts[length]) > uint64(1)<<length {
+			return nil, errors.New("code lengths are over-subscribed")
+		}
+		next[length] = code
+	}
+	for _, symbol := range table.symbols {
+		length := lengths[symbol]
+		table.codes[symbol] = next[length]
+		next[length] += 1
+	}
+	return table, nil
+}
+
+// ReadCodeTable reads a table serialised with WriteTo or MarshalBinary.
+func ReadCodeTable(reader io.Reader) (*CodeTable, error) {
+	b := make([]byte, 2)
+	if _, err := io.ReadFull(reader, b); err != nil {
+		return nil, ErrInvalidStructure
+	}
+	lengths := make([]uint8, binary.LittleEndian.Uint16(b))
+	if _, err := io.ReadFull(reader, lengths); err != nil {
+		return nil, ErrInvalidStructure
+	}
+	table, err := NewCodeTableFromLengths(lengths)
+	if err != nil {
+		return nil, ErrInvalidStructure
+	}
+	return table, nil
+}
+
+// ReadCodeTableFromHeader reads the tree stored at the start of a stream of
+// the plain Huffman method, with or without a file header of either format
+// version, and returns a table over the 256 byte values with the code
+// lengths of the tree. The codes of a CodeTable are canonical, so they are
+// in general not the codes of the tree itself; ReadTree returns those.
+func ReadCodeTableFromHeader(reader io.Reader) (*CodeTable, error) {
+	tree, err := ReadTree(reader)
+	if err != nil {
+		return nil, err
+	}
+	lengths := make([]uint8, 256)
+	for symbol, length := range codeLengths(tree.root, len(lengths)) {
+		if length > maxCodeLength {
+			return nil, fmt.Errorf("code length %d exceeds %d bits", length, maxCodeLength)
+		}
+		lengths[symbol] = uint8(length)
+	}
+	return NewCodeTableFromLengths(lengths)
+}
+
+func (table *CodeTable) AlphabetSize() int {
+	return len(table.lengths)
+}
+
+func (table *CodeTable) Lengths() []uint8 {
+	return slices.Clone(table.lengths)
+}
+
+// Code returns the code of the symbol right-aligned in a uint64 and its
+// length in bits. The length is zero for symbols without a code.
+func (table *CodeTable) Code(symbol uint16) (uint64, uint8) {
+	if int(symbol) >= len(table.lengths) {
+		return 0, 0
+	}
+	return table.codes[symbol], table.lengths[symbol]
+}
+
+func (table *CodeTable) Encode(writer *bitio.Writer, symbol uint16) error {
+	code, length := table.Code(symbol)
+	if length == 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownSymbol, symbol)
+	}
+	return writer.WriteBitsUint64(code, length)
+}
+
+type bitReader interface {
+	ReadBit() (byte, error)
+}
+
+func (table *CodeTable) Decode(reader *bitio.Reader) (uint16, error) {
+	return table.decode(reader)
+}
+
+func (table *CodeTable) decode(reader bitReader) (uint16, error) {
+	var code, first uint64 = 0, 0
+	index := 0
+	for length := 1; length <= table.maxLength; length++ {
+		bit, err := reader.ReadBit()
+		if err != nil {
+			return 0, err
+		}
+		code |= uint64(bit)
+		count := uint64(table.counts[length])
+		if code < first+count {
+			return table.symbols[index+int(code-first)], nil
+		}
+		index += int(count)
+		first = (first + count) << 1
+		code <<= 1
+	}
+	return 0, ErrInvalidStructure
+}
+
+func (table *CodeTable) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 2, 2+len(table.lengths))
+	binary.LittleEndian.PutUint16(b, uint16(len(table.lengths)))
+	return append(b, table.lengths...), nil
+}
+
+func (table *CodeTable) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 || len(data)-2 != int(binary.LittleEndian.Uint16(data)) {
+		return ErrInvalidStructure
+	}
+	result, err := NewCodeTableFromLengths(data[2:])
+	if err != nil {
+		return ErrInvalidStructure
+	}
+	*table = *result
+	return nil
+}
+
+func (table *CodeTable) WriteTo(writer io.Writer) (int64, error) {
+	b, _ := table.MarshalBinary()
+	written, err := writer.Write(b)
+	return int64(written), err
+}
+
+func formatCode(code uint64, length uint8) string {
+	if length == 0 {
+		return ""
+	}
+	result := strconv.FormatUint(code, 2)
+	for len(result) < int(length) {
+		result = "0" + result
+	}
+	return result
+}
+
+func (table *CodeTable) MarshalJSON() ([]byte, error) {
+	result := codeTableJSON{len(table.lengths), make([]codeEntry, 0, len(table.symbols))}
+	for symbol, length := range table.lengths {
+		if length > 0 {
+			code := formatCode(table.codes[symbol], length)
+			result.Codes = append(result.Codes, codeEntry{uint16(symbol), length, code})
+		}
+	}
+	return json.Marshal(result)
+}
+
+func (table *CodeTable) UnmarshalJSON(data []byte) error {
+	var source codeTableJSON
+	if err := json.Unmarshal(data, &source); err != nil {
+		return err
+	}
+	if source.AlphabetSize < 0 || source.AlphabetSize > maxAlphabetSize {
+		return fmt.Errorf("invalid alphabet size: %d", source.AlphabetSize)
+	}
+	lengths := make([]uint8, source.AlphabetSize)
+	for _, entry := range source.Codes {
+		if int(entry.Symbol) >= len(lengths) {
+			return fmt.Errorf("symbol %d is outside of the alphabet", entry.Symbol)
+		}
+		lengths[entry.Symbol] = entry.Length
+	}
+	result, err := NewCodeTableFromLengths(lengths)
+	if err != nil {
+		return err
+	}
+	for _, entry := range source.Codes {
+		code, length := result.Code(entry.Symbol)
+		if entry.Code != "" && entry.Code != formatCode(code, length) {
+			return fmt.Errorf("code %q of symbol %d is not canonical", entry.Code, entry.Symbol)
+		}
+	}
+	*table = *result
+	return nil
+}
@@ -0,0 +1,327 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/serrhiy/go-huffman/benchkit"
+	"github.com/serrhiy/go-huffman/bitio"
+)
+
+func frequenciesOf(data []byte, alphabetSize int) []uint {
+	frequencies := make([]uint, alphabetSize)
+	for _, b := range data {
+		frequencies[b] += 1
+	}
+	return frequencies
+}
+
+func TestNewCodeTableFromLengths(t *testing.T) {
+	t.Run("canonical codes", func(t *testing.T) {
+		// example from RFC 1951, section 3.2.2
+		table, err := NewCodeTableFromLengths([]uint8{3, 3, 3, 3, 3, 2, 4, 4})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := []string{"010", "011", "100", "101", "110", "00", "1110", "1111"}
+		for symbol, code := range expected {
+			if result := formatCode(table.Code(uint16(symbol))); result != code {
+				t.Fatalf("symbol %d: expected code %s, got: %s", symbol, code, result)
+			}
+		}
+	})
+
+	t.Run("over-subscribed", func(t *testing.T) {
+		if _, err := NewCodeTableFromLengths([]uint8{1, 1, 1}); err == nil {
+			t.Fatal("expected error, got: <nil>")
+		}
+	})
+
+	t.Run("too long", func(t *testing.T) {
+		if _, err := NewCodeTableFromLengths([]uint8{maxCodeLength + 1}); err == nil {
+			t.Fatal("expected error, got: <nil>")
+		}
+	})
+
+	t.Run("incomplete", func(t *testing.T) {
+		table, err := NewCodeTableFromLengths([]uint8{0, 1})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		buf := &bytes.Buffer{}
+		writer := bitio.NewWriter(buf)
+		writer.WriteBit(1)
+		writer.Flush()
+		if _, err := table.Decode(bitio.NewReader(buf)); err != ErrInvalidStructure {
+			t.Fatalf("expected ErrInvalidStructure for unassigned code, got: %v", err)
+		}
+	})
+
+	t.Run("unknown symbol", func(t *testing.T) {
+		table, err := NewCodeTableFromLengths([]uint8{1, 0, 1})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		writer := bitio.NewWriter(&bytes.Buffer{})
+		for _, symbol := range []uint16{1, 3} {
+			if err := table.Encode(writer, symbol); !errors.Is(err, ErrUnknownSymbol) {
+				t.Fatalf("symbol %d: expected ErrUnknownSymbol, got: %v", symbol, err)
+			}
+		}
+	})
+}
+
+func TestNewLimitedCodeTable(t *testing.T) {
+	// Fibonacci frequencies produce the deepest possible tree
+	fibonacci := []uint{1, 1}
+	for len(fibonacci) < 30 {
+		fibonacci = append(fibonacci, fibonacci[len(fibonacci)-1]+fibonacci[len(fibonacci)-2])
+	}
+
+	t.Run("limited", func(t *testing.T) {
+		for _, limit := range []int{5, 7, 15} {
+			table, err := NewLimitedCodeTable(fibonacci, limit)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var kraft float64 = 0
+			for symbol, length := range table.Lengths() {
+				if length == 0 || int(length) > limit {
+					t.Fatalf("limit %d: invalid length %d for symbol %d", limit, length, symbol)
+				}
+				kraft += 1 / float64(uint64(1)<<length)
+			}
+			if kraft != 1 {
+				t.Fatalf("limit %d: code is not complete, kraft sum: %f", limit, kraft)
+			}
+		}
+	})
+
+	t.Run("within limit", func(t *testing.T) {
+		frequencies := frequenciesOf([]byte(benchkit.Text(4096)), 256)
+		limited, err := NewLimitedCodeTable(frequencies, maxCodeLength)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		table, _ := NewCodeTable(frequencies)
+		if !slices.Equal(limited.Lengths(), table.Lengths()) {
+			t.Fatal("lengths must not change when the code fits into the limit")
+		}
+	})
+
+	t.Run("too many symbols", func(t *testing.T) {
+		if _, err := NewLimitedCodeTable([]uint{1, 1, 1, 1, 1}, 2); err == nil {
+			t.Fatal("expected error, got: <nil>")
+		}
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		if _, err := NewLimitedCodeTable([]uint{1, 1}, 0); err == nil {
+			t.Fatal("expected error, got: <nil>")
+		}
+	})
+}
+
+func TestCodeTableRoundTrip(t *testing.T) {
+	inputs := map[string][]byte{
+		"single":  []byte("aaaa"),
+		"text":    []byte(benchkit.Text(4096)),
+		"random":  []byte(benchkit.Random(4096)),
+		"skewed":  append(bytes.Repeat([]byte{'x'}, 1000), "abcdefgh"...),
+		"alldata": []byte(benchkit.Range(0, 256)),
+	}
+	for name, data := range inputs {
+		t.Run(name, func(t *testing.T) {
+			table, err := NewCodeTable(frequenciesOf(data, 256))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			buf := &bytes.Buffer{}
+			writer := bitio.NewWriter(buf)
+			for _, b := range data {
+				if err := table.Encode(writer, uint16(b)); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			writer.Flush()
+
+			root := buildSymbolTree(frequenciesOf(data, 256))
+			lengths := codeLengths(root, 256)
+			var expectedBits int
+			for _, b := range data {
+				expectedBits += lengths[b]
+			}
+			if buf.Len() != (expectedBits+7)/8 {
+				t.Fatalf("invalid encoded size, expected: %d, got: %d", (expectedBits+7)/8, buf.Len())
+			}
+
+			reader := bitio.NewReader(buf)
+			for i, b := range data {
+				symbol, err := table.Decode(reader)
+				if err != nil {
+					t.Fatalf("unexpected error at %d: %v", i, err)
+				}
+				if symbol != uint16(b) {
+					t.Fatalf("invalid symbol at %d, expected: %d, got: %d", i, b, symbol)
+				}
+			}
+		})
+	}
+
+	t.Run("large alphabet", func(t *testing.T) {
+		frequencies := make([]uint, 300)
+		for i := range frequencies {
+			frequencies[i] = uint(i%7 + 1)
+		}
+		table, err := NewCodeTable(frequencies)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		buf := &bytes.Buffer{}
+		writer := bitio.NewWriter(buf)
+		for symbol := range 300 {
+			table.Encode(writer, uint16(symbol))
+		}
+		writer.Flush()
+		reader := bitio.NewReader(buf)
+		for expected := range 300 {
+			symbol, err := table.Decode(reader)
+			if err != nil || symbol != uint16(expected) {
+				t.Fatalf("expected symbol %d, got: %d, err: %v", expected, symbol, err)
+			}
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		table, err := NewCodeTable(make([]uint, 256))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := table.Decode(bitio.NewReader(bytes.NewReader([]byte{0}))); err != ErrInvalidStructure {
+			t.Fatalf("expected ErrInvalidStructure, got: %v", err)
+		}
+	})
+}
+
+func TestCodeTableSerialization(t *testing.T) {
+	table, err := NewCodeTable(frequenciesOf([]byte("abracadabra"), 256))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("binary", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		if _, err := table.WriteTo(buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data, _ := table.MarshalBinary()
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Fatalf("WriteTo and MarshalBinary differ: %v, %v", buf.Bytes(), data)
+		}
+		result, err := ReadCodeTable(buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(result.Lengths(), table.Lengths()) || !slices.Equal(result.codes, table.codes) {
+			t.Fatalf("tables differ after round-trip")
+		}
+		var unmarshaled CodeTable
+		if err := unmarshaled.UnmarshalBinary(data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(unmarshaled.codes, table.codes) {
+			t.Fatalf("tables differ after round-trip")
+		}
+	})
+
+	t.Run("invalid binary", func(t *testing.T) {
+		data, _ := table.MarshalBinary()
+		var result CodeTable
+		if err := result.UnmarshalBinary(data[:len(data)-1]); err != ErrInvalidStructure {
+			t.Fatalf("expected ErrInvalidStructure, got: %v", err)
+		}
+		if _, err := ReadCodeTable(bytes.NewReader(data[:10])); err != ErrInvalidStructure {
+			t.Fatalf("expected ErrInvalidStructure, got: %v", err)
+		}
+		if err := result.UnmarshalBinary([]byte{3, 0, 1, 1, 1}); err != ErrInvalidStructure {
+			t.Fatalf("expected ErrInvalidStructure, got: %v", err)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		data, err := json.Marshal(table)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		nonCanonical := `{"alphabet_size":256,"codes":[` +
+			`{"symbol":97,"length":1,"code":"0"},` +
+			`{"symbol":98,"length":3,"code":"110"},` +
+			`{"symbol":99,"length":3,"code":"111"},` +
+			`{"symbol":100,"length":3,"code":"100"},` +
+			`{"symbol":114,"length":3,"code":"101"}]}`
+		var result CodeTable
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(result.codes, table.codes) || !slices.Equal(result.lengths, table.lengths) {
+			t.Fatalf("tables differ after round-trip: %s", data)
+		}
+		if err := json.Unmarshal([]byte(nonCanonical), &result); err == nil {
+			t.Fatal("expected error for non-canonical code")
+		}
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		var result CodeTable
+		inputs := []string{
+			`{"alphabet_size":-1}`,
+			`{"alphabet_size":2,"codes":[{"symbol":5,"length":1}]}`,
+			`{"alphabet_size":3,"codes":[{"symbol":0,"length":1},{"symbol":1,"length":1},{"symbol":2,"length":1}]}`,
+			`[]`,
+		}
+		for _, input := range inputs {
+			if err := json.Unmarshal([]byte(input), &result); err == nil {
+				t.Fatalf("expected error for %s", input)
+			}
+		}
+	})
+}
+
+func TestReadCodeTableFromHeader(t *testing.T) {
+	input := "abracadabra"
+	expected, err := NewCodeTable(frequenciesOf([]byte(input), 256))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	headerless := encodeStream(t, input, EncoderOptions{})
+	streams := map[string][]byte{
+		"headerless": headerless,
+		"version 1":  append([]byte{'H', 'F', legacyFormatVersion, methodHuffman, 0}, headerless...),
+		"version 2":  encodeStream(t, input, EncoderOptions{Header: &Header{Name: "input.txt"}}),
+	}
+	for name, stream := range streams {
+		table, err := ReadCodeTableFromHeader(bytes.NewReader(stream))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if !slices.Equal(table.Lengths(), expected.Lengths()) || !slices.Equal(table.codes, expected.codes) {
+			t.Fatalf("%s: expected lengths %v, got: %v", name, expected.Lengths(), table.Lengths())
+		}
+	}
+
+	t.Run("other methods", func(t *testing.T) {
+		stream := encodeStream(t, input, EncoderOptions{Level: 6})
+		if _, err := ReadCodeTableFromHeader(bytes.NewReader(stream)); err != ErrUnsupportedFormat {
+			t.Fatalf("expected ErrUnsupportedFormat, got: %v", err)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		if _, err := ReadCodeTableFromHeader(bytes.NewReader(headerless[:3])); err != ErrInvalidStructure {
+			t.Fatalf("expected ErrInvalidStructure, got: %v", err)
+		}
+	})
+}
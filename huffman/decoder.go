@@ -2,76 +2,224 @@ package huffman
 
 import (
 	"bufio"
+	"context"
 	"encoding/binary"
 	"errors"
 	"io"
+	"math"
 
 	"github.com/serrhiy/go-huffman/bitio"
 )
 
 var ErrInvalidStructure = errors.New("invalid file structure")
 
+type DecoderOptions struct {
+	// Frequencies must match EncoderOptions.Frequencies for streams encoded
+	// with a shared table.
+	Frequencies map[byte]uint
+	// RawDeflate reads a raw DEFLATE stream instead of the .hfm format.
+	RawDeflate bool
+	// Progress reports the number of encoded bytes consumed so far and the
+	// number of decoded bytes written.
+	Progress ProgressFunc
+}
+
 type HuffmanDecoder struct {
-	reader *bufio.Reader
-	writer *bufio.Writer
+	reader   *bufio.Reader
+	writer   *bufio.Writer
+	header   *Header
+	version  byte
+	options  DecoderOptions
+	progress *progressTracker
+	// ctx is the context of the running DecodeContext call
+	ctx context.Context
+
+	// buffers kept across Reset; reader and writer are either these or
+	// buffered readers and writers passed in by the caller
+	input    *bufio.Reader
+	output   *bufio.Writer
+	bits     *bitio.Reader
+	inflater *inflater
+	tree     nodeArena
+	// shared code table of the options and its checksum, built by the first
+	// Decode of a stream that omits the table
+	shared         *CodeTable
+	sharedChecksum uint32
 }
 
 func NewDecoder(reader io.Reader, writer io.Writer) *HuffmanDecoder {
-	return &HuffmanDecoder{bufio.NewReader(reader), bufio.NewWriter(writer)}
+	return NewDecoderWithOptions(reader, writer, DecoderOptions{})
 }
 
-func _readTree(reader *bitio.Reader, length uint16) (*node, error) {
-	var readed uint16 = 0
-	var next func() (*node, error)
-	next = func() (*node, error) {
-		if readed >= length {
-			return nil, nil
-		}
-		bit, err := reader.ReadBit()
-		if err != nil {
-			return nil, err
-		}
-		readed += 1
-		if bit == 1 {
-			if readed+8 > length {
-				return nil, ErrInvalidStructure
-			}
-			b, err := reader.ReadByte()
-			if err != nil {
-				return nil, err
-			}
-			readed += 8
-			return &node{b, 0, nil, nil}, nil
+func NewDecoderWithOptions(reader io.Reader, writer io.Writer, options DecoderOptions) *HuffmanDecoder {
+	decoder := &HuffmanDecoder{options: options}
+	decoder.Reset(reader, writer)
+	return decoder
+}
+
+// Reset makes the decoder decode reader to writer with the same options. It
+// keeps the buffers allocated by previous calls to Decode, so a decoder can
+// be reused for many streams, for example through a sync.Pool.
+func (decoder *HuffmanDecoder) Reset(reader io.Reader, writer io.Writer) {
+	decoder.progress = newProgressTracker(decoder.options.Progress)
+	decoder.ctx = context.Background()
+	reader, writer = decoder.progress.reader(reader), decoder.progress.writer(writer)
+
+	// like bufio.NewReader and bufio.NewWriter, use buffered ones directly
+	if buffered, ok := reader.(*bufio.Reader); ok && buffered.Size() >= bufioSize {
+		decoder.reader = buffered
+	} else {
+		if decoder.input == nil {
+			decoder.input = bufio.NewReader(reader)
+		} else {
+			decoder.input.Reset(reader)
 		}
-		left, err := next()
-		if err != nil {
-			return nil, err
+		decoder.reader = decoder.input
+	}
+	if buffered, ok := writer.(*bufio.Writer); ok && buffered.Size() >= bufioSize {
+		decoder.writer = buffered
+	} else {
+		if decoder.output == nil {
+			decoder.output = bufio.NewWriter(writer)
+		} else {
+			decoder.output.Reset(writer)
 		}
-		if readed > length {
-			return &node{0, 0, left, nil}, nil
+		decoder.writer = decoder.output
+	}
+	decoder.header, decoder.version = nil, legacyFormatVersion
+}
+
+// bitReader returns the bit reader of the decoder reset to its input.
+func (decoder *HuffmanDecoder) bitReader() *bitio.Reader {
+	if decoder.bits == nil {
+		decoder.bits = bitio.NewReader(decoder.reader)
+	} else {
+		decoder.bits.Reset(decoder.reader)
+	}
+	return decoder.bits
+}
+
+// Header returns the file header read by Decode, or nil if the stream had none.
+func (decoder *HuffmanDecoder) Header() *Header {
+	return decoder.header
+}
+
+func (decoder *HuffmanDecoder) readFileHeader() (byte, error) {
+	ok, err := hasFileHeader(decoder.reader)
+	if err != nil {
+		if err == io.EOF {
+			return 0, ErrInvalidStructure
 		}
+		return 0, err
+	}
+	if !ok {
+		return methodHuffman, nil
+	}
+	header, method, version, err := readFileHeader(decoder.reader)
+	if err != nil {
+		return 0, err
+	}
+	decoder.header, decoder.version = header, version
+	return method, nil
+}
+
+// treeReader is implemented by bitio.Reader and sliceBitReader.
+type treeReader interface {
+	ReadBit() (byte, error)
+	ReadByte() (byte, error)
+}
 
-		right, err := next()
+func _readTree(reader treeReader, length uint16, arena *nodeArena) (*node, error) {
+	// a tree of n leaves takes 10n-1 bits and has 2n-1 nodes
+	arena.reset(min(int(length)/5+1, 2*256))
+	var readed uint16 = 0
+	return readNode(reader, length, &readed, arena)
+}
+
+func readNode(reader treeReader, length uint16, readed *uint16, arena *nodeArena) (*node, error) {
+	if *readed >= length {
+		return nil, nil
+	}
+	bit, err := reader.ReadBit()
+	if err != nil {
+		return nil, err
+	}
+	*readed += 1
+	if bit == 1 {
+		if *readed+8 > length {
+			return nil, ErrInvalidStructure
+		}
+		b, err := reader.ReadByte()
 		if err != nil {
 			return nil, err
 		}
-		return &node{0, 0, left, right}, nil
+		*readed += 8
+		return arena.new(node{uint16(b), 0, nil, nil, 0}), nil
+	}
+	left, err := readNode(reader, length, readed, arena)
+	if err != nil {
+		return nil, err
+	}
+	if *readed > length {
+		return arena.new(node{0, 0, left, nil, 0}), nil
 	}
-	return next()
+
+	right, err := readNode(reader, length, readed, arena)
+	if err != nil {
+		return nil, err
+	}
+	return arena.new(node{0, 0, left, right, 0}), nil
 }
 
-func readTree(reader *bitio.Reader) (*node, error) {
-	buffer := make([]byte, 2)
-	if _, err := io.ReadFull(reader, buffer); err != nil {
+// readTree reads the tree size field of the given format version and the
+// tree itself, allocating its nodes from arena.
+func readTree(reader *bitio.Reader, version byte, arena *nodeArena) (*node, error) {
+	if version > legacyFormatVersion {
+		size, err := reader.ReadUvarint()
+		if err != nil || size > math.MaxUint16 {
+			return nil, ErrInvalidStructure
+		}
+		return _readTree(reader, uint16(size), arena)
+	}
+	var buffer [2]byte
+	if _, err := io.ReadFull(reader, buffer[:]); err != nil {
 		return nil, err
 	}
-	headerSize := binary.LittleEndian.Uint16(buffer)
-	return _readTree(reader, headerSize)
+	headerSize := binary.LittleEndian.Uint16(buffer[:])
+	return _readTree(reader, headerSize, arena)
 }
 
 func (decoder *HuffmanDecoder) Decode() error {
-	reader := bitio.NewReader(decoder.reader)
-	root, err := readTree(reader)
+	return decoder.DecodeContext(context.Background())
+}
+
+// DecodeContext is like Decode but stops at the next block or buffer
+// boundary once ctx is done and returns ctx.Err(). The output then holds a
+// prefix of the decoded content: data buffered at that point is dropped.
+func (decoder *HuffmanDecoder) DecodeContext(ctx context.Context) error {
+	decoder.ctx = ctx
+	defer func() { decoder.ctx = context.Background() }()
+	if decoder.options.RawDeflate {
+		return decoder.decodeDeflate(false)
+	}
+	method, err := decoder.readFileHeader()
+	if err != nil {
+		return err
+	}
+	switch method {
+	case methodHuffman:
+		return decoder.decodeHuffman()
+	case methodStatic:
+		return decoder.decodeStatic()
+	case methodLZ77Reuse:
+		return decoder.decodeDeflate(true)
+	}
+	return ErrUnsupportedFormat
+}
+
+func (decoder *HuffmanDecoder) decodeHuffman() error {
+	reader := decoder.bitReader()
+	root, err := readTree(reader, decoder.version, &decoder.tree)
 	if err != nil {
 		if err == io.EOF {
 			return ErrInvalidStructure
@@ -82,12 +230,19 @@ func (decoder *HuffmanDecoder) Decode() error {
 		return err
 	}
 
-	buffer := make([]byte, 8)
-	if _, err := io.ReadFull(reader, buffer); err != nil {
-		return ErrInvalidStructure
+	var length uint64
+	if decoder.version > legacyFormatVersion {
+		if length, err = reader.ReadUvarint(); err != nil {
+			return ErrInvalidStructure
+		}
+	} else {
+		var buffer [8]byte
+		if _, err := io.ReadFull(reader, buffer[:]); err != nil {
+			return ErrInvalidStructure
+		}
+		length = binary.LittleEndian.Uint64(buffer[:])
 	}
-	length := binary.LittleEndian.Uint64(buffer)
-	writer := bufio.NewWriter(decoder.writer)
+	writer := decoder.writer
 	current := root
 	var total uint64 = 0
 
@@ -97,6 +252,11 @@ func (decoder *HuffmanDecoder) Decode() error {
 	}
 
 	for total < length {
+		if total%(bufferSize*8) == 0 {
+			if err := decoder.ctx.Err(); err != nil {
+				return err
+			}
+		}
 		bit, err := reader.ReadBit()
 		if err != nil {
 			if err == io.EOF {
@@ -118,9 +278,13 @@ func (decoder *HuffmanDecoder) Decode() error {
 		}
 
 		if current.isLeaf() {
-			writer.WriteByte(current.char)
+			writer.WriteByte(byte(current.char))
 			current = root
 		}
 	}
-	return writer.Flush()
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	decoder.progress.done()
+	return nil
 }
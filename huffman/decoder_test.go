@@ -2,12 +2,16 @@ package huffman
 
 import (
 	"bytes"
+	"context"
 	"io"
 
 	"encoding/binary"
 	"errors"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/serrhiy/go-huffman/benchkit"
 	"github.com/serrhiy/go-huffman/bitio"
 )
 
@@ -37,7 +41,7 @@ func (b *brokenReader) Seek(offset int64, whence int) (int64, error) {
 func TestReadTree(t *testing.T) {
 	t.Run("empty", func(t *testing.T) {
 		reader := bitio.NewReader(&bytes.Buffer{})
-		root, err := readTree(reader)
+		root, err := readTree(reader, legacyFormatVersion, &nodeArena{})
 		if err != io.EOF {
 			t.Fatalf("eof error expected, got: %v", err)
 		}
@@ -49,7 +53,7 @@ func TestReadTree(t *testing.T) {
 	t.Run("error propagation", func(t *testing.T) {
 		source := []byte{10, 0, 0b01110000, 0b01000000}
 		broken := &brokenReader{limit: 2, reader: bytes.NewReader(source)}
-		root, err := readTree(bitio.NewReader(broken))
+		root, err := readTree(bitio.NewReader(broken), legacyFormatVersion, &nodeArena{})
 		if err == nil {
 			t.Fatal("expected error, got: <nil>")
 		}
@@ -61,7 +65,7 @@ func TestReadTree(t *testing.T) {
 	t.Run("1 leaf", func(t *testing.T) {
 		source := []byte{10, 0, 0b01011000, 0b01000000}
 		reader := bytes.NewReader(source)
-		root, err := readTree(bitio.NewReader(reader))
+		root, err := readTree(bitio.NewReader(reader), legacyFormatVersion, &nodeArena{})
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -89,7 +93,7 @@ func TestReadTree(t *testing.T) {
 		writer.WriteByte('B')
 		writer.Flush()
 
-		root, err := readTree(bitio.NewReader(buf))
+		root, err := readTree(bitio.NewReader(buf), legacyFormatVersion, &nodeArena{})
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -218,3 +222,103 @@ func FuzzDecodeGarbage(f *testing.F) {
 		}
 	})
 }
+
+func TestDecoderReset(t *testing.T) {
+	for name, options := range resetOptions() {
+		t.Run(name, func(t *testing.T) {
+			decoderOptions := DecoderOptions{Frequencies: options.Frequencies, RawDeflate: options.RawDeflate}
+			decoder := NewDecoderWithOptions(nil, nil, decoderOptions)
+			headerless := name == "headerless" || name == "raw"
+			for _, message := range resetMessages {
+				encoded := &bytes.Buffer{}
+				if err := NewEncoderWithOptions(strings.NewReader(message), encoded, options).Encode(); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				output := &bytes.Buffer{}
+				decoder.Reset(encoded, output)
+				if err := decoder.Decode(); err != nil {
+					t.Fatalf("message of %d bytes: unexpected error: %v", len(message), err)
+				}
+				if output.String() != message {
+					t.Fatalf("message of %d bytes: invalid output of %d bytes", len(message), output.Len())
+				}
+				if (decoder.Header() == nil) != headerless {
+					t.Fatalf("header left over from another stream: %v", decoder.Header())
+				}
+			}
+		})
+	}
+
+	t.Run("after an error", func(t *testing.T) {
+		decoder := NewDecoder(strings.NewReader("HF\x02\x02\x00garbage"), io.Discard)
+		if err := decoder.Decode(); err == nil {
+			t.Fatalf("expected an error")
+		}
+		encoded := &bytes.Buffer{}
+		NewEncoderWithOptions(strings.NewReader("hello"), encoded, EncoderOptions{Level: 6}).Encode()
+		output := &bytes.Buffer{}
+		decoder.Reset(encoded, output)
+		if err := decoder.Decode(); err != nil || output.String() != "hello" {
+			t.Fatalf("expected hello, got: %q, %v", output.String(), err)
+		}
+	})
+}
+
+func BenchmarkDecodeReset(b *testing.B) {
+	message := resetMessages[3]
+	for name, options := range resetOptions() {
+		b.Run(name, func(b *testing.B) {
+			encoded := &bytes.Buffer{}
+			if err := NewEncoderWithOptions(strings.NewReader(message), encoded, options).Encode(); err != nil {
+				b.Fatalf("unexpected error: %v", err)
+			}
+			decoderOptions := DecoderOptions{Frequencies: options.Frequencies, RawDeflate: options.RawDeflate}
+			pool := sync.Pool{New: func() any { return NewDecoderWithOptions(nil, nil, decoderOptions) }}
+			reader := bytes.NewReader(encoded.Bytes())
+			output := &bytes.Buffer{}
+			b.ReportAllocs()
+			for b.Loop() {
+				reader.Reset(encoded.Bytes())
+				output.Reset()
+				decoder := pool.Get().(*HuffmanDecoder)
+				decoder.Reset(reader, output)
+				if err := decoder.Decode(); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+				pool.Put(decoder)
+			}
+		})
+	}
+}
+
+func TestDecodeContext(t *testing.T) {
+	input := benchkit.Text(1 << 20)
+	for name, options := range resetOptions() {
+		if options.Frequencies != nil {
+			options.Frequencies = map[byte]uint{}
+			for i := range 256 {
+				options.Frequencies[byte(i)] = 1
+			}
+		}
+		t.Run(name, func(t *testing.T) {
+			encoded := encodeStream(t, input, options)
+			decoderOptions := DecoderOptions{Frequencies: options.Frequencies, RawDeflate: options.RawDeflate}
+			for _, limit := range []int{0, 1, len(encoded) / 2} {
+				ctx, cancel := context.WithCancel(context.Background())
+				if limit == 0 {
+					cancel()
+				}
+				reader := &cancelReader{bytes.NewReader(encoded), limit, cancel}
+				output := &bytes.Buffer{}
+				err := NewDecoderWithOptions(reader, output, decoderOptions).DecodeContext(ctx)
+				cancel()
+				if !errors.Is(err, context.Canceled) {
+					t.Fatalf("limit %d: expected context.Canceled, got: %v", limit, err)
+				}
+				if !strings.HasPrefix(input, output.String()) || output.Len() == len(input) {
+					t.Fatalf("limit %d: output of %d bytes is not a proper prefix of the input", limit, output.Len())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,504 @@
+package huffman
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"slices"
+
+	"github.com/serrhiy/go-huffman/bitio"
+)
+
+// DEFLATE (RFC 1951) output. The input is cut into blocks, and each of them
+// is written as a dynamic Huffman block of literals and, with LZ77, of
+// length/distance pairs.
+const (
+	endOfBlock           = 256
+	literalAlphabetSize  = 286
+	distanceAlphabetSize = 30
+	maxDeflateCodeLength = 15
+	maxLengthCodeLength  = 7
+	blockStored          = 0
+	blockFixed           = 1
+	blockDynamic         = 2
+	// blockReuse is the block type reserved by DEFLATE. Streams of
+	// methodLZ77Reuse use it for blocks coded with the tables of the last
+	// dynamic block. Code lengths sent as a delta from those tables were
+	// left out: the delta still needs the code length table and a run of
+	// lengths, so it costs about as much as the run-length coded header of
+	// a dynamic block, while reuse costs no bits at all.
+	blockReuse = 3
+	maxLevel   = 9
+)
+
+// compressionLevel describes how the input is coded at a level: the LZ77
+// match finder level, zero for literals only, the number of input bytes read
+// at once, whether every block uses the fixed tables, whether the smallest
+// of stored, fixed and dynamic coding is picked for every block, and whether
+// the input read at once is split into blocks where its statistics change.
+type compressionLevel struct {
+	match     int
+	blockSize int
+	fixed     bool
+	choose    bool
+	split     bool
+}
+
+// level zero is used for raw DEFLATE output without LZ77
+var compressionLevels = [maxLevel + 1]compressionLevel{
+	0: {0, 1 << 16, false, false, false},
+	1: {1, 1 << 18, true, false, false},
+	2: {2, 1 << 18, false, false, false},
+	3: {3, 1 << 17, false, false, false},
+	4: {4, 1 << 16, false, false, false},
+	5: {5, 1 << 16, false, true, false},
+	6: {6, 1 << 16, false, true, false},
+	7: {7, 1 << 16, false, true, false},
+	8: {8, 1 << 18, false, true, true},
+	9: {9, 1 << 18, false, true, true},
+}
+
+// lengths and distances of back references are coded as a symbol for the
+// base value followed by extra bits
+var lengthBase = [...]uint16{
+	3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31,
+	35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 258,
+}
+var lengthExtraBits = [...]uint8{
+	0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2,
+	3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0,
+}
+var distanceBase = [...]uint16{
+	1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193,
+	257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577,
+}
+var distanceExtraBits = [...]uint8{
+	0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6,
+	7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13,
+}
+
+// baseIndex returns the index of the largest base not greater than value.
+func baseIndex(bases []uint16, value uint16) int {
+	index, found := slices.BinarySearch(bases, value)
+	if !found {
+		index -= 1
+	}
+	return index
+}
+
+// order in which the code lengths of the code length alphabet are stored
+var codeLengthOrder = [...]uint8{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+
+// deflateWriter writes DEFLATE blocks. With reuseTables, blocks may be coded
+// with the tables of the previous dynamic block.
+type deflateWriter struct {
+	writer      *bitio.Writer
+	reuseTables bool
+	previous    *blockEncoding
+}
+
+func newDeflateWriter(writer io.Writer, reuseTables bool) *deflateWriter {
+	return &deflateWriter{bitio.NewWriterWithOrder(writer, bitio.LSBFirst), reuseTables, nil}
+}
+
+// reset makes the writer start a new stream written to w.
+func (writer *deflateWriter) reset(w io.Writer) {
+	writer.writer.Reset(w)
+	writer.previous = nil
+}
+
+// writeSymbol stores the code starting from its most significant bit.
+func (writer *deflateWriter) writeSymbol(table *CodeTable, symbol uint16) error {
+	code, length := table.Code(symbol)
+	if length == 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownSymbol, symbol)
+	}
+	return writer.writer.WriteBitsUint64(bits.Reverse64(code)>>(64-length), length)
+}
+
+type lengthCode struct {
+	symbol uint8
+	extra  uint8
+}
+
+var lengthCodeExtraBits = map[uint8]byte{16: 2, 17: 3, 18: 7}
+var lengthCodeRepeat = map[uint8]uint64{16: 3, 17: 3, 18: 11}
+
+// runLengthCodes encodes code lengths with the code length alphabet: 0-15
+// are lengths, 16 repeats the previous length 3-6 times, 17 and 18 repeat
+// zero 3-10 and 11-138 times.
+func runLengthCodes(lengths []uint8) []lengthCode {
+	result := make([]lengthCode, 0, len(lengths))
+	for i := 0; i < len(lengths); {
+		length := lengths[i]
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == length {
+			run += 1
+		}
+		i += run
+		if length == 0 {
+			for run >= 11 {
+				n := min(run, 138)
+				result = append(result, lengthCode{18, uint8(n - 11)})
+				run -= n
+			}
+			if run >= 3 {
+				result = append(result, lengthCode{17, uint8(run - 3)})
+				run = 0
+			}
+		} else {
+			result = append(result, lengthCode{length, 0})
+			run -= 1
+			for run >= 3 {
+				n := min(run, 6)
+				result = append(result, lengthCode{16, uint8(n - 3)})
+				run -= n
+			}
+		}
+		for ; run > 0; run-- {
+			result = append(result, lengthCode{length, 0})
+		}
+	}
+	return result
+}
+
+func trimLengths(lengths []uint8, minimum int) []uint8 {
+	size := len(lengths)
+	for size > minimum && lengths[size-1] == 0 {
+		size -= 1
+	}
+	return lengths[:size]
+}
+
+// dynamicHeader holds the code lengths of a dynamic block coded with the code
+// length alphabet.
+type dynamicHeader struct {
+	literalCount  int
+	distanceCount int
+	codes         []lengthCode
+	table         *CodeTable
+	lengths       []uint8
+}
+
+func newDynamicHeader(literals, distances *CodeTable) (*dynamicHeader, error) {
+	literalLengths := trimLengths(literals.Lengths(), 257)
+	distanceLengths := trimLengths(distances.Lengths(), 1)
+	codes := runLengthCodes(append(literalLengths, distanceLengths...))
+
+	frequencies := make([]uint, len(codeLengthOrder))
+	for _, code := range codes {
+		frequencies[code.symbol] += 1
+	}
+	table, err := NewLimitedCodeTable(frequencies, maxLengthCodeLength)
+	if err != nil {
+		return nil, err
+	}
+	lengths := make([]uint8, len(codeLengthOrder))
+	for i, symbol := range codeLengthOrder {
+		lengths[i] = table.lengths[symbol]
+	}
+	lengths = trimLengths(lengths, 4)
+	return &dynamicHeader{len(literalLengths), len(distanceLengths), codes, table, lengths}, nil
+}
+
+// size returns the size of the header in bits.
+func (header *dynamicHeader) size() int {
+	size := 14 + 3*len(header.lengths)
+	for _, code := range header.codes {
+		size += int(header.table.lengths[code.symbol]) + int(lengthCodeExtraBits[code.symbol])
+	}
+	return size
+}
+
+func (writer *deflateWriter) writeDynamicHeader(header *dynamicHeader) error {
+	counts := uint64(header.literalCount-257) | uint64(header.distanceCount-1)<<5 | uint64(len(header.lengths)-4)<<10
+	if err := writer.writer.WriteBitsUint64(counts, 14); err != nil {
+		return err
+	}
+	for _, length := range header.lengths {
+		if err := writer.writer.WriteBitsUint64(uint64(length), 3); err != nil {
+			return err
+		}
+	}
+	for _, code := range header.codes {
+		if err := writer.writeSymbol(header.table, uint16(code.symbol)); err != nil {
+			return err
+		}
+		if extra, ok := lengthCodeExtraBits[code.symbol]; ok {
+			if err := writer.writer.WriteBitsUint64(uint64(code.extra), extra); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (writer *deflateWriter) writeToken(literals, distances *CodeTable, token lzToken) error {
+	if token.length == 0 {
+		return writer.writeSymbol(literals, uint16(token.literal))
+	}
+	index := baseIndex(lengthBase[:], token.length)
+	if err := writer.writeSymbol(literals, uint16(endOfBlock+1+index)); err != nil {
+		return err
+	}
+	extra := uint64(token.length - lengthBase[index])
+	if err := writer.writer.WriteBitsUint64(extra, lengthExtraBits[index]); err != nil {
+		return err
+	}
+	index = baseIndex(distanceBase[:], token.distance)
+	if err := writer.writeSymbol(distances, uint16(index)); err != nil {
+		return err
+	}
+	extra = uint64(token.distance - distanceBase[index])
+	return writer.writer.WriteBitsUint64(extra, distanceExtraBits[index])
+}
+
+func tokenFrequencies(tokens []lzToken) ([]uint, []uint) {
+	literals := make([]uint, literalAlphabetSize)
+	distances := make([]uint, distanceAlphabetSize)
+	for _, token := range tokens {
+		if token.length == 0 {
+			literals[token.literal] += 1
+			continue
+		}
+		literals[endOfBlock+1+baseIndex(lengthBase[:], token.length)] += 1
+		distances[baseIndex(distanceBase[:], token.distance)] += 1
+	}
+	literals[endOfBlock] = 1
+	return literals, distances
+}
+
+// blockEncoding is the block type with the tables coding its content.
+type blockEncoding struct {
+	kind      uint64
+	literals  *CodeTable
+	distances *CodeTable
+	header    *dynamicHeader
+}
+
+var fixedLiterals, fixedDistances = fixedTables()
+
+func dynamicEncoding(literalFrequencies, distanceFrequencies []uint) (blockEncoding, error) {
+	literals, err := NewLimitedCodeTable(literalFrequencies, maxDeflateCodeLength)
+	if err != nil {
+		return blockEncoding{}, err
+	}
+	// without back references all distance lengths are zero, which is
+	// stored as a single zero length
+	distances, err := NewLimitedCodeTable(distanceFrequencies, maxDeflateCodeLength)
+	if err != nil {
+		return blockEncoding{}, err
+	}
+	header, err := newDynamicHeader(literals, distances)
+	if err != nil {
+		return blockEncoding{}, err
+	}
+	return blockEncoding{blockDynamic, literals, distances, header}, nil
+}
+
+// size returns the size of the block in bits, header included.
+func (encoding blockEncoding) size(literalFrequencies, distanceFrequencies []uint) int {
+	size := 3
+	if encoding.header != nil {
+		size += encoding.header.size()
+	}
+	for symbol, count := range literalFrequencies {
+		size += int(count) * int(encoding.literals.lengths[symbol])
+		if symbol > endOfBlock {
+			size += int(count) * int(lengthExtraBits[symbol-endOfBlock-1])
+		}
+	}
+	for symbol, count := range distanceFrequencies {
+		size += int(count) * (int(encoding.distances.lengths[symbol]) + int(distanceExtraBits[symbol]))
+	}
+	return size
+}
+
+// storedSize returns the size in bits of data written as stored blocks,
+// assuming the worst alignment.
+func storedSize(size int) int {
+	blocks := max(1, (size+math.MaxUint16-1)/math.MaxUint16)
+	return blocks*(3+7+32) + 8*size
+}
+
+func (writer *deflateWriter) writeStored(data []byte, final bool) error {
+	for first := true; first || len(data) > 0; first = false {
+		size := min(len(data), math.MaxUint16)
+		var header uint64 = blockStored << 1
+		if final && size == len(data) {
+			header |= 1
+		}
+		if err := writer.writer.WriteBitsUint64(header, 3); err != nil {
+			return err
+		}
+		if err := writer.writer.Align(); err != nil {
+			return err
+		}
+		if err := writer.writer.WriteBitsUint64(uint64(size)|uint64(^uint16(size))<<16, 32); err != nil {
+			return err
+		}
+		if _, err := writer.writer.Write(data[:size]); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// covers reports whether the tables have a code for every symbol used.
+func (encoding blockEncoding) covers(literalFrequencies, distanceFrequencies []uint) bool {
+	for symbol, count := range literalFrequencies {
+		if count > 0 && encoding.literals.lengths[symbol] == 0 {
+			return false
+		}
+	}
+	for symbol, count := range distanceFrequencies {
+		if count > 0 && (symbol >= len(encoding.distances.lengths) || encoding.distances.lengths[symbol] == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeBlock codes tokens, produced from data, as one or, when stored,
+// several blocks.
+func (writer *deflateWriter) writeBlock(tokens []lzToken, data []byte, final bool, level compressionLevel) error {
+	literalFrequencies, distanceFrequencies := tokenFrequencies(tokens)
+	candidates := []blockEncoding{}
+	if level.fixed || level.choose {
+		candidates = append(candidates, blockEncoding{blockFixed, fixedLiterals, fixedDistances, nil})
+	}
+	if !level.fixed {
+		dynamic, err := dynamicEncoding(literalFrequencies, distanceFrequencies)
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, dynamic)
+	}
+	if writer.previous != nil && writer.previous.covers(literalFrequencies, distanceFrequencies) {
+		previous := *writer.previous
+		candidates = append(candidates, blockEncoding{blockReuse, previous.literals, previous.distances, nil})
+	}
+	encoding, size := candidates[0], -1
+	for _, candidate := range candidates {
+		if candidateSize := candidate.size(literalFrequencies, distanceFrequencies); size < 0 || candidateSize < size {
+			encoding, size = candidate, candidateSize
+		}
+	}
+	if level.choose && storedSize(len(data)) < size {
+		return writer.writeStored(data, final)
+	}
+
+	header := encoding.kind << 1
+	if final {
+		header |= 1
+	}
+	if err := writer.writer.WriteBitsUint64(header, 3); err != nil {
+		return err
+	}
+	if encoding.header != nil {
+		if err := writer.writeDynamicHeader(encoding.header); err != nil {
+			return err
+		}
+	}
+	for _, token := range tokens {
+		if err := writer.writeToken(encoding.literals, encoding.distances, token); err != nil {
+			return err
+		}
+	}
+	if encoding.kind == blockDynamic && writer.reuseTables {
+		writer.previous = &encoding
+	}
+	return writer.writeSymbol(encoding.literals, endOfBlock)
+}
+
+// splitLevel codes the blocks of SplitBlocks without a level: large chunks
+// of input and the cheapest coding of every block, like level 9.
+var splitLevel = compressionLevel{0, 1 << 18, false, true, true}
+
+// deflateLevel returns the preset of the Level option adjusted for
+// SplitBlocks. Blocks of the fixed tables are never split, as their cost
+// does not depend on where they end.
+func (encoder *HuffmanEncoder) deflateLevel() compressionLevel {
+	level := compressionLevels[encoder.options.Level]
+	if encoder.options.SplitBlocks && !level.fixed {
+		if encoder.options.Level == 0 {
+			level = splitLevel
+		}
+		level.split = true
+	}
+	return level
+}
+
+func (encoder *HuffmanEncoder) encodeDeflate() error {
+	level := encoder.deflateLevel()
+	finder := encoder.finder
+	if finder != nil {
+		finder.reset()
+	} else if encoder.options.LZ77 != nil || level.match > 0 {
+		options := LZ77Options{Level: level.match}
+		if encoder.options.LZ77 != nil {
+			options = *encoder.options.LZ77
+			if options.Level == 0 {
+				options.Level = level.match
+			}
+		}
+		var err error
+		if finder, err = newMatchFinder(options); err != nil {
+			return err
+		}
+		encoder.finder = finder
+	}
+	reader := encoder.inputReader(encoder.progress.reader(encoder.reader))
+	if encoder.deflate == nil {
+		encoder.deflate = newDeflateWriter(encoder.writer, !encoder.options.RawDeflate)
+	} else {
+		encoder.deflate.reset(encoder.writer)
+	}
+	writer := encoder.deflate
+	if encoder.block == nil {
+		encoder.block = make([]byte, level.blockSize)
+		encoder.tokens = make([]lzToken, 0, level.blockSize)
+	}
+	block, tokens := encoder.block, encoder.tokens
+	for {
+		if err := encoder.ctx.Err(); err != nil {
+			return err
+		}
+		readed, err := io.ReadFull(reader, block)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		final := err != nil
+		if !final {
+			if _, err := reader.Peek(1); err == io.EOF {
+				final = true
+			} else if err != nil {
+				return err
+			}
+		}
+		if finder != nil {
+			tokens = finder.tokens(block[:readed], tokens[:0])
+		} else {
+			tokens = literalTokens(block[:readed], tokens[:0])
+		}
+		if level.split {
+			err = writer.writeSplitBlocks(tokens, block[:readed], final, level)
+		} else {
+			err = writer.writeBlock(tokens, block[:readed], final, level)
+		}
+		if err != nil {
+			return err
+		}
+		if final {
+			break
+		}
+	}
+	encoder.tokens = tokens
+	if err := writer.writer.Flush(); err != nil {
+		return err
+	}
+	encoder.progress.done()
+	return nil
+}
@@ -0,0 +1,173 @@
+package huffman
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/serrhiy/go-huffman/benchkit"
+)
+
+func TestRunLengthCodes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		lengths  []uint8
+		expected []lengthCode
+	}{
+		{"short runs", []uint8{3, 3, 0, 0}, []lengthCode{{3, 0}, {3, 0}, {0, 0}, {0, 0}}},
+		{"repeat previous", []uint8{5, 5, 5, 5, 5, 5, 5, 5}, []lengthCode{{5, 0}, {16, 3}, {5, 0}}},
+		{"short zeros", slices.Repeat([]uint8{0}, 10), []lengthCode{{17, 7}}},
+		{"long zeros", slices.Repeat([]uint8{0}, 150), []lengthCode{{18, 127}, {18, 1}}},
+		{"zeros remainder", slices.Repeat([]uint8{0}, 140), []lengthCode{{18, 127}, {0, 0}, {0, 0}}},
+		{"zeros tail", slices.Repeat([]uint8{0}, 141), []lengthCode{{18, 127}, {17, 0}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := runLengthCodes(tc.lengths); !slices.Equal(result, tc.expected) {
+				t.Fatalf("expected codes %v, got: %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestRawDeflate(t *testing.T) {
+	inputs := map[string][]byte{
+		"empty":     {},
+		"single":    []byte("a"),
+		"repeating": bytes.Repeat([]byte("z"), 1000),
+		"text":      []byte(benchkit.Text(10000)),
+		"random":    []byte(benchkit.Random(10000)),
+		"blocks":    []byte(benchkit.Text(3*compressionLevels[0].blockSize + 17)),
+		"exact":     []byte(benchkit.Text(compressionLevels[0].blockSize)),
+	}
+	for name, source := range inputs {
+		t.Run(name, func(t *testing.T) {
+			encoded := encodeStatic(t, source, EncoderOptions{RawDeflate: true})
+			decoded, err := io.ReadAll(flate.NewReader(bytes.NewReader(encoded)))
+			if err != nil {
+				t.Fatalf("compress/flate rejected the stream: %v", err)
+			}
+			if !bytes.Equal(decoded, source) {
+				t.Fatal("invalid content decoded by compress/flate")
+			}
+			writer := &bytes.Buffer{}
+			decoder := NewDecoderWithOptions(bytes.NewReader(encoded), writer, DecoderOptions{RawDeflate: true})
+			if err := decoder.Decode(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(writer.Bytes(), source) {
+				t.Fatal("invalid decoded content")
+			}
+		})
+	}
+
+	t.Run("compresses", func(t *testing.T) {
+		source := []byte(benchkit.Text(1 << 16))
+		encoded := encodeStatic(t, source, EncoderOptions{RawDeflate: true})
+		if len(encoded) >= len(source) {
+			t.Fatalf("expected output smaller than %d bytes, got: %d", len(source), len(encoded))
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		encoded := encodeStatic(t, []byte("hello world"), EncoderOptions{RawDeflate: true})
+		decoder := NewDecoderWithOptions(bytes.NewReader(encoded[:len(encoded)/2]), &bytes.Buffer{}, DecoderOptions{RawDeflate: true})
+		if err := decoder.Decode(); err != ErrInvalidStructure {
+			t.Fatalf("expected ErrInvalidStructure, got: %v", err)
+		}
+	})
+}
+
+func TestLevels(t *testing.T) {
+	inputs := map[string][]byte{
+		"empty":  {},
+		"text":   []byte(benchkit.Text(300000)),
+		"random": []byte(benchkit.Random(100000)),
+		"mixed":  []byte(benchkit.Text(50000) + benchkit.Random(100000) + benchkit.Text(50000)),
+	}
+	for name, source := range inputs {
+		for level := 1; level <= maxLevel; level++ {
+			encoded := encodeStatic(t, source, EncoderOptions{Level: level})
+			writer := &bytes.Buffer{}
+			if err := NewDecoder(bytes.NewReader(encoded), writer).Decode(); err != nil {
+				t.Fatalf("%s, level %d: unexpected error: %v", name, level, err)
+			}
+			if !bytes.Equal(writer.Bytes(), source) {
+				t.Fatalf("%s, level %d: invalid decoded content", name, level)
+			}
+			raw := encodeStatic(t, source, EncoderOptions{Level: level, RawDeflate: true})
+			decoded, err := io.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+			if err != nil || !bytes.Equal(decoded, source) {
+				t.Fatalf("%s, level %d: compress/flate failed to decode the stream: %v", name, level, err)
+			}
+		}
+	}
+
+	t.Run("fixed tables", func(t *testing.T) {
+		encoded := encodeStatic(t, []byte("hello"), EncoderOptions{Level: 1, RawDeflate: true})
+		if blockType := encoded[0] >> 1 & 0b11; blockType != blockFixed {
+			t.Fatalf("expected fixed block, got type: %d", blockType)
+		}
+	})
+
+	t.Run("stored", func(t *testing.T) {
+		source := inputs["random"]
+		encoded := encodeStatic(t, source, EncoderOptions{Level: 9, RawDeflate: true})
+		if len(encoded) > len(source)+len(source)/1000 {
+			t.Fatalf("expected incompressible data to be stored, size: %d, encoded: %d", len(source), len(encoded))
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, level := range []int{-1, maxLevel + 1} {
+			encoder := NewEncoderWithOptions(bytes.NewReader(nil), &bytes.Buffer{}, EncoderOptions{Level: level})
+			if err := encoder.Encode(); err == nil {
+				t.Fatalf("level %d: expected error, got: <nil>", level)
+			}
+		}
+	})
+}
+
+func TestTableReuse(t *testing.T) {
+	source := []byte(strings.Repeat("2024-01-01 INFO handled request id=12345 status=200 in 12ms\n", 30000))
+	options := EncoderOptions{LZ77: &LZ77Options{}}
+	encoded := encodeStatic(t, source, options)
+	writer := &bytes.Buffer{}
+	if err := NewDecoder(bytes.NewReader(encoded), writer).Decode(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(writer.Bytes(), source) {
+		t.Fatal("invalid decoded content")
+	}
+
+	options.RawDeflate = true
+	raw := encodeStatic(t, source, options)
+	decoded, err := io.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+	if err != nil || !bytes.Equal(decoded, source) {
+		t.Fatalf("compress/flate failed to decode the stream: %v", err)
+	}
+	if len(encoded) >= len(raw) {
+		t.Fatalf("expected reused tables to save space, raw: %d, encoded: %d", len(raw), len(encoded))
+	}
+
+	// the same blocks without the .hfm header are not a valid DEFLATE stream
+	if _, err := inflate(encoded[5:]); err != ErrInvalidStructure {
+		t.Fatalf("expected ErrInvalidStructure, got: %v", err)
+	}
+
+	t.Run("method", func(t *testing.T) {
+		if encoded[3] != methodLZ77Reuse {
+			t.Fatalf("expected method %d, got: %d", methodLZ77Reuse, encoded[3])
+		}
+		for _, method := range []byte{methodLZ77Reuse - 1, methodLZ77Reuse + 1} {
+			unknown := bytes.Clone(encoded)
+			unknown[3] = method
+			if err := NewDecoder(bytes.NewReader(unknown), io.Discard).Decode(); err != ErrUnsupportedFormat {
+				t.Fatalf("method %d: expected ErrUnsupportedFormat, got: %v", method, err)
+			}
+		}
+	})
+}
@@ -2,7 +2,10 @@ package huffman
 
 import (
 	"bufio"
+	"context"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 
 	"github.com/serrhiy/go-huffman/bitio"
@@ -10,42 +13,208 @@ import (
 
 const bufferSize = 32 * 1024
 
+// bufioSize is the default buffer size of package bufio.
+const bufioSize = 4096
+
+var ErrNotSeekable = errors.New("input must be seekable unless a frequency table is provided")
+
+type EncoderOptions struct {
+	// Header is written in front of the encoded data when set. Without it,
+	// and without Frequencies, the output has no file header at all.
+	Header *Header
+	// Frequencies replaces the counting pass over the input, so the input
+	// is read only once and does not have to be seekable. Every byte of the
+	// input must have a non-zero frequency.
+	Frequencies map[byte]uint
+	// SharedTable omits the code table derived from Frequencies from the
+	// output. The decoder must then be given the same Frequencies.
+	SharedTable bool
+	// TreeBuilder selects the algorithm used to build the tree. All of them
+	// produce optimal codes.
+	TreeBuilder TreeBuilder
+	// Level selects a preset between 1 (fastest) and 9 (smallest output) for
+	// the LZ77 mode; zero keeps the plain Huffman coding unless LZ77 is set.
+	// Low levels use the fixed DEFLATE tables or few large blocks, high
+	// levels search longer for matches and use smaller blocks, each coded
+	// with the cheapest of its own tables, the fixed tables or no coding.
+	// No level transforms the input before coding.
+	Level int
+	// LZ77 replaces repeated strings with back references before Huffman
+	// coding. The content is stored as a DEFLATE stream, read in a single
+	// pass, so the input does not have to be seekable. Frequencies,
+	// SharedTable and TreeBuilder are ignored. The options take precedence
+	// over the match finder settings of Level.
+	LZ77 *LZ77Options
+	// SplitBlocks ends blocks where the statistics of the input change
+	// enough for new tables to pay for themselves, instead of after a fixed
+	// amount of input, and is implied by levels 8 and 9. Without LZ77, Level
+	// and Frequencies the bytes are Huffman coded in such blocks, stored
+	// like the LZ77 mode without back references, so the input is read once.
+	// It is ignored at level 1, which always uses the fixed tables, and with
+	// Frequencies, which define a single table.
+	SplitBlocks bool
+	// RawDeflate writes a raw DEFLATE stream (RFC 1951) that any inflater,
+	// such as compress/flate, can read, instead of the .hfm format. The input
+	// does not have to be seekable. Header, Frequencies, SharedTable and
+	// TreeBuilder are ignored. Combined with LZ77 the stream contains back
+	// references as well.
+	RawDeflate bool
+	// Progress reports the number of input bytes encoded so far and the
+	// number of bytes written.
+	Progress ProgressFunc
+}
+
 type HuffmanEncoder struct {
-	reader io.ReadSeeker
-	writer io.Writer
+	reader   io.Reader
+	writer   io.Writer
+	options  EncoderOptions
+	progress *progressTracker
+	// ctx is the context of the running EncodeContext call
+	ctx context.Context
+
+	// buffers, tables and match finder state kept across Reset
+	counts  [256]uint
+	tree    nodeArena
+	codes   codeBook
+	input   *bufio.Reader
+	bits    *bitio.Writer
+	buffer  []byte
+	deflate *deflateWriter
+	finder  *matchFinder
+	block   []byte
+	tokens  []lzToken
+	// static code table of the options and the bytes written before the
+	// content, built by the first static Encode
+	static       *CodeTable
+	staticPrefix []byte
+}
+
+func NewEncoder(reader io.Reader, writer io.Writer) *HuffmanEncoder {
+	return NewEncoderWithOptions(reader, writer, EncoderOptions{})
+}
+
+func NewEncoderWithOptions(reader io.Reader, writer io.Writer, options EncoderOptions) *HuffmanEncoder {
+	encoder := &HuffmanEncoder{options: options}
+	encoder.Reset(reader, writer)
+	return encoder
 }
 
-func NewEncoder(reader io.ReadSeeker, writer io.Writer) *HuffmanEncoder {
-	return &HuffmanEncoder{reader, writer}
+// Reset makes the encoder encode reader to writer with the same options. It
+// keeps the buffers allocated by previous calls to Encode, so an encoder can
+// be reused for many streams, for example through a sync.Pool.
+func (encoder *HuffmanEncoder) Reset(reader io.Reader, writer io.Writer) {
+	encoder.progress = newProgressTracker(encoder.options.Progress)
+	encoder.ctx = context.Background()
+	encoder.reader = reader
+	encoder.writer = encoder.progress.writer(writer)
+}
+
+// inputReader returns the buffered reader of the encoder reset to reader.
+func (encoder *HuffmanEncoder) inputReader(reader io.Reader) *bufio.Reader {
+	if encoder.input == nil {
+		encoder.input = bufio.NewReaderSize(reader, bufferSize)
+	} else {
+		encoder.input.Reset(reader)
+	}
+	if encoder.buffer == nil {
+		encoder.buffer = make([]byte, bufferSize)
+	}
+	return encoder.input
+}
+
+// bitWriter returns the bit writer of the encoder reset to its output.
+func (encoder *HuffmanEncoder) bitWriter() *bitio.Writer {
+	if encoder.bits == nil {
+		encoder.bits = bitio.NewWriter(encoder.writer)
+	} else {
+		encoder.bits.Reset(encoder.writer)
+	}
+	return encoder.bits
+}
+
+func (encoder *HuffmanEncoder) rewind() error {
+	seeker, ok := encoder.reader.(io.Seeker)
+	if !ok {
+		return ErrNotSeekable
+	}
+	_, err := seeker.Seek(0, io.SeekStart)
+	return err
 }
 
 func (encoder *HuffmanEncoder) Encode() error {
-	if _, err := encoder.reader.Seek(0, io.SeekStart); err != nil {
+	return encoder.EncodeContext(context.Background())
+}
+
+// EncodeContext is like Encode but stops at the next block or buffer
+// boundary once ctx is done and returns ctx.Err(). The output then holds a
+// prefix of the complete stream: data buffered at that point is dropped.
+func (encoder *HuffmanEncoder) EncodeContext(ctx context.Context) error {
+	encoder.ctx = ctx
+	defer func() { encoder.ctx = context.Background() }()
+	if encoder.options.Level < 0 || encoder.options.Level > maxLevel {
+		return fmt.Errorf("invalid compression level: %d", encoder.options.Level)
+	}
+	if encoder.options.RawDeflate {
+		return encoder.encodeDeflate()
+	}
+	split := encoder.options.SplitBlocks && encoder.options.Frequencies == nil
+	if encoder.options.LZ77 != nil || encoder.options.Level > 0 || split {
+		header := encoder.options.Header
+		if header == nil {
+			header = &Header{}
+		}
+		if err := writeFileHeader(encoder.writer, header, methodLZ77Reuse); err != nil {
+			return err
+		}
+		return encoder.encodeDeflate()
+	}
+	if encoder.options.Frequencies != nil {
+		return encoder.encodeStatic()
+	}
+	if err := encoder.rewind(); err != nil {
 		return err
 	}
-	frequencies, err := getFrequencyMap(encoder.reader)
-	if err != nil {
+	encoder.counts = [256]uint{}
+	if err := countBytes(encoder.ctx, encoder.inputReader(encoder.progress.counter(encoder.reader)), encoder.buffer, &encoder.counts); err != nil {
 		return err
 	}
-	root := buildTree(frequencies)
-	codes := buildCodes(root)
+	root := encoder.options.TreeBuilder.buildCounts(&encoder.counts, &encoder.tree)
+	encoder.codes.build(root)
+	if encoder.options.Header != nil {
+		if err := writeFileHeader(encoder.writer, encoder.options.Header, methodHuffman); err != nil {
+			return err
+		}
+	}
 	if err := encoder.writeHeader(root); err != nil {
 		return err
 	}
-	if err := encoder.encodeContent(codes, frequencies); err != nil {
+	if err := encoder.encodeContent(&encoder.codes, encoder.codes.contentSize(&encoder.counts)); err != nil {
 		return err
 	}
+	encoder.progress.done()
 	return nil
 }
 
+// varintFields tells whether the tree size and the content length are
+// varints: streams with a file header use the current format version,
+// headerless ones keep the fixed-size fields.
+func (encoder *HuffmanEncoder) varintFields() bool {
+	return encoder.options.Header != nil
+}
+
 func (encoder *HuffmanEncoder) writeHeader(root *node) error {
-	b := make([]byte, 2)
 	treeSize := calculateTreeSize(root)
-	binary.LittleEndian.PutUint16(b, treeSize)
-
-	bitWriter := bitio.NewWriter(encoder.writer)
-	if _, err := bitWriter.Write(b); err != nil {
-		return err
+	bitWriter := encoder.bitWriter()
+	if encoder.varintFields() {
+		if err := bitWriter.WriteUvarint(uint64(treeSize)); err != nil {
+			return err
+		}
+	} else {
+		b := make([]byte, 2)
+		binary.LittleEndian.PutUint16(b, treeSize)
+		if _, err := bitWriter.Write(b); err != nil {
+			return err
+		}
 	}
 	if err := writeCodes(root, bitWriter); err != nil {
 		return err
@@ -56,22 +225,32 @@ func (encoder *HuffmanEncoder) writeHeader(root *node) error {
 	return nil
 }
 
-func (encoder *HuffmanEncoder) encodeContent(codes map[byte]string, freq map[byte]uint) error {
-	if _, err := encoder.reader.Seek(0, io.SeekStart); err != nil {
+// encodeContent writes the content length in bits followed by the codes of
+// the input.
+func (encoder *HuffmanEncoder) encodeContent(codes *codeBook, length uint64) error {
+	if err := encoder.rewind(); err != nil {
 		return err
 	}
-	reader := bufio.NewReader(encoder.reader)
-	writer := bitio.NewWriter(encoder.writer)
-	buffer := make([]byte, bufferSize)
-	length, _ := calculateContentSize(codes, freq)
+	reader := encoder.inputReader(encoder.progress.reader(encoder.reader))
+	writer := encoder.bitWriter()
+	buffer := encoder.buffer
 
-	b := make([]byte, 8)
-	binary.LittleEndian.PutUint64(b, length)
-	if _, err := writer.Write(b); err != nil {
-		return err
+	if encoder.varintFields() {
+		if err := writer.WriteUvarint(length); err != nil {
+			return err
+		}
+	} else {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, length)
+		if _, err := writer.Write(b); err != nil {
+			return err
+		}
 	}
 
 	for {
+		if err := encoder.ctx.Err(); err != nil {
+			return err
+		}
 		readed, err := reader.Read(buffer)
 		if err != nil {
 			if err == io.EOF {
@@ -80,16 +259,8 @@ func (encoder *HuffmanEncoder) encodeContent(codes map[byte]string, freq map[byt
 			return err
 		}
 		for i := range readed {
-			code := codes[buffer[i]]
-			for j := range code {
-				if code[j] == '1' {
-					err = writer.WriteBit(1)
-				} else {
-					err = writer.WriteBit(0)
-				}
-				if err != nil {
-					return err
-				}
+			if err := codes.write(writer, buffer[i]); err != nil {
+				return err
 			}
 		}
 	}
@@ -2,11 +2,15 @@ package huffman
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"io"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/serrhiy/go-huffman/benchkit"
 	"github.com/serrhiy/go-huffman/bitio"
 )
 
@@ -147,7 +151,7 @@ func TestEncodeContent(t *testing.T) {
 		writer := &bytes.Buffer{}
 		reader := bytes.NewReader([]byte{})
 		encoder := NewEncoder(reader, writer)
-		if err := encoder.encodeContent(map[byte]string{}, map[byte]uint{}); err != nil {
+		if err := encoder.encodeContent(&codeBook{}, 0); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 		content := writer.Bytes()
@@ -164,9 +168,8 @@ func TestEncodeContent(t *testing.T) {
 	t.Run("error propagation", func(t *testing.T) {
 		reader := bytes.NewReader([]byte("aaa"))
 		encoder := NewEncoder(reader, &failingWriter{limit: 3, writer: &bytes.Buffer{}})
-		codes := map[byte]string{'a': "0"}
-		freq := map[byte]uint{'a': 3}
-		if err := encoder.encodeContent(codes, freq); err == nil {
+		codes := &codeBook{streams: codeStreams(map[byte]string{'a': "0"})}
+		if err := encoder.encodeContent(codes, 3); err == nil {
 			t.Fatalf("expected writer error")
 		}
 	})
@@ -241,3 +244,162 @@ func TestEncode(t *testing.T) {
 
 	// other cases should be covered in fuzzing tests
 }
+
+func TestEncodeDeterministic(t *testing.T) {
+	inputs := map[string][]byte{
+		"ties":   []byte("abcdefghijklmnopqrstuvwxyz"),
+		"range":  []byte(benchkit.Range(0, 256)),
+		"text":   []byte(benchkit.Text(10_000)),
+		"random": []byte(benchkit.Random(10_000)),
+	}
+	for name, source := range inputs {
+		t.Run(name, func(t *testing.T) {
+			var expected []byte
+			for i := range 50 {
+				writer := &bytes.Buffer{}
+				if err := NewEncoder(bytes.NewReader(source), writer).Encode(); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if i == 0 {
+					expected = writer.Bytes()
+				} else if !bytes.Equal(expected, writer.Bytes()) {
+					t.Fatalf("run %d produced different output", i)
+				}
+			}
+		})
+	}
+}
+
+var resetMessages = []string{
+	"",
+	"a",
+	"hello, hello, hello world",
+	`{"id":42,"name":"gopher","tags":["go","huffman","deflate"],"ok":true}`,
+	strings.Repeat("abcabcabd", 300),
+}
+
+func resetOptions() map[string]EncoderOptions {
+	frequencies := map[byte]uint{}
+	for _, message := range resetMessages {
+		for i := range len(message) {
+			frequencies[message[i]] += 1
+		}
+	}
+	return map[string]EncoderOptions{
+		"headerless": {},
+		"header":     {Header: &Header{}},
+		"static":     {Frequencies: frequencies},
+		"shared":     {Frequencies: frequencies, SharedTable: true},
+		"level 1":    {Level: 1},
+		"level 6":    {Level: 6},
+		"level 9":    {Level: 9},
+		"lz77":       {LZ77: &LZ77Options{Window: minWindow}},
+		"raw":        {RawDeflate: true, Level: 6},
+	}
+}
+
+func TestEncoderReset(t *testing.T) {
+	for name, options := range resetOptions() {
+		t.Run(name, func(t *testing.T) {
+			var encoder *HuffmanEncoder
+			for _, message := range resetMessages {
+				expected := &bytes.Buffer{}
+				if err := NewEncoderWithOptions(strings.NewReader(message), expected, options).Encode(); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				output := &bytes.Buffer{}
+				if encoder == nil {
+					encoder = NewEncoderWithOptions(strings.NewReader(message), output, options)
+				} else {
+					encoder.Reset(strings.NewReader(message), output)
+				}
+				if err := encoder.Encode(); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !bytes.Equal(output.Bytes(), expected.Bytes()) {
+					t.Fatalf("message of %d bytes: output differs from a new encoder", len(message))
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEncodeReset(b *testing.B) {
+	message := resetMessages[3]
+	for name, options := range resetOptions() {
+		b.Run(name, func(b *testing.B) {
+			pool := sync.Pool{New: func() any { return NewEncoderWithOptions(nil, nil, options) }}
+			reader := strings.NewReader(message)
+			output := &bytes.Buffer{}
+			b.ReportAllocs()
+			for b.Loop() {
+				reader.Reset(message)
+				output.Reset()
+				encoder := pool.Get().(*HuffmanEncoder)
+				encoder.Reset(reader, output)
+				if err := encoder.Encode(); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+				pool.Put(encoder)
+			}
+		})
+	}
+}
+
+// cancelReader cancels a context once limit bytes have been read.
+type cancelReader struct {
+	reader io.Reader
+	limit  int
+	cancel context.CancelFunc
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if r.limit -= n; r.limit <= 0 {
+		r.cancel()
+	}
+	return n, err
+}
+
+func (r *cancelReader) Seek(offset int64, whence int) (int64, error) {
+	return r.reader.(io.Seeker).Seek(offset, whence)
+}
+
+func TestEncodeContext(t *testing.T) {
+	input := benchkit.Text(1 << 20)
+	for name, options := range resetOptions() {
+		if options.Frequencies != nil {
+			options.Frequencies = map[byte]uint{}
+			for i := range 256 {
+				options.Frequencies[byte(i)] = 1
+			}
+		}
+		t.Run(name, func(t *testing.T) {
+			complete := encodeStream(t, input, options)
+			for _, limit := range []int{0, 1, 100_000} {
+				ctx, cancel := context.WithCancel(context.Background())
+				if limit == 0 {
+					cancel()
+				}
+				reader := &cancelReader{strings.NewReader(input), limit, cancel}
+				output := &bytes.Buffer{}
+				err := NewEncoderWithOptions(reader, output, options).EncodeContext(ctx)
+				cancel()
+				if !errors.Is(err, context.Canceled) {
+					t.Fatalf("limit %d: expected context.Canceled, got: %v", limit, err)
+				}
+				if !bytes.HasPrefix(complete, output.Bytes()) || output.Len() == len(complete) {
+					t.Fatalf("limit %d: output of %d bytes is not a proper prefix of the stream", limit, output.Len())
+				}
+			}
+		})
+	}
+
+	t.Run("not canceled", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		err := NewEncoder(strings.NewReader(input), output).EncodeContext(context.Background())
+		if err != nil || !bytes.Equal(output.Bytes(), encodeStream(t, input, EncoderOptions{})) {
+			t.Fatalf("unexpected result, error: %v", err)
+		}
+	})
+}
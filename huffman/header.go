@@ -0,0 +1,181 @@
+package huffman
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"time"
+)
+
+// File header layout (all integers are little endian):
+//
+//	magic   [2]byte  "HF"
+//	version byte
+//	method  byte
+//	flags   byte
+//	name    uint16 length + bytes   (flagName)
+//	mode    uint32                  (flagMode)
+//	mtime   int64 unix nanoseconds  (flagModTime)
+//
+// The magic can never be confused with the legacy headerless format: there the
+// first two bytes hold the tree size, which is at most 256*9+255 bits.
+//
+// Version 1 stores the tree size and the content length of methodHuffman
+// streams as uint16 and uint64 like the headerless format, version 2 as
+// LEB128 varints.
+var magic = [2]byte{'H', 'F'}
+
+const (
+	legacyFormatVersion = 1
+	formatVersion       = 2
+)
+
+// methodLZ77Reuse streams are DEFLATE blocks that may also reuse the tables
+// of the last dynamic block. The id 2 is reserved and rejected like any other
+// unknown method.
+const (
+	methodHuffman byte = iota
+	methodStatic
+	_
+	methodLZ77Reuse
+)
+
+const (
+	flagName byte = 1 << iota
+	flagMode
+	flagModTime
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported file format")
+
+// Header holds optional metadata about the original file. Zero values are
+// not stored.
+type Header struct {
+	Name    string
+	Mode    fs.FileMode
+	ModTime time.Time
+}
+
+func (header *Header) flags() byte {
+	var flags byte = 0
+	if header.Name != "" {
+		flags |= flagName
+	}
+	if header.Mode != 0 {
+		flags |= flagMode
+	}
+	if !header.ModTime.IsZero() {
+		flags |= flagModTime
+	}
+	return flags
+}
+
+var errNameTooLong = errors.New("file name is too long")
+
+// size returns the number of bytes writeFileHeader writes for the header.
+func (header *Header) size() (uint64, error) {
+	if len(header.Name) > math.MaxUint16 {
+		return 0, errNameTooLong
+	}
+	flags := header.flags()
+	size := uint64(5)
+	if flags&flagName != 0 {
+		size += 2 + uint64(len(header.Name))
+	}
+	if flags&flagMode != 0 {
+		size += 4
+	}
+	if flags&flagModTime != 0 {
+		size += 8
+	}
+	return size, nil
+}
+
+func writeFileHeader(writer io.Writer, header *Header, method byte) error {
+	if len(header.Name) > math.MaxUint16 {
+		return errNameTooLong
+	}
+	flags := header.flags()
+	b := []byte{magic[0], magic[1], formatVersion, method, flags}
+	if flags&flagName != 0 {
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(header.Name)))
+		b = append(b, header.Name...)
+	}
+	if flags&flagMode != 0 {
+		b = binary.LittleEndian.AppendUint32(b, uint32(header.Mode))
+	}
+	if flags&flagModTime != 0 {
+		b = binary.LittleEndian.AppendUint64(b, uint64(header.ModTime.UnixNano()))
+	}
+	_, err := writer.Write(b)
+	return err
+}
+
+func hasFileHeader(reader *bufio.Reader) (bool, error) {
+	b, err := reader.Peek(len(magic))
+	if err != nil {
+		return false, err
+	}
+	return b[0] == magic[0] && b[1] == magic[1], nil
+}
+
+// readFileHeader returns the header, the method and the format version.
+func readFileHeader(reader io.Reader) (*Header, byte, byte, error) {
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(reader, b); err != nil {
+		return nil, 0, 0, ErrInvalidStructure
+	}
+	if b[0] != magic[0] || b[1] != magic[1] {
+		return nil, 0, 0, ErrInvalidStructure
+	}
+	if b[2] < legacyFormatVersion || b[2] > formatVersion {
+		return nil, 0, 0, ErrUnsupportedFormat
+	}
+	version, method, flags := b[2], b[3], b[4]
+	header := &Header{}
+	if flags&flagName != 0 {
+		if _, err := io.ReadFull(reader, b[:2]); err != nil {
+			return nil, 0, 0, ErrInvalidStructure
+		}
+		name := make([]byte, binary.LittleEndian.Uint16(b))
+		if _, err := io.ReadFull(reader, name); err != nil {
+			return nil, 0, 0, ErrInvalidStructure
+		}
+		header.Name = string(name)
+	}
+	if flags&flagMode != 0 {
+		if _, err := io.ReadFull(reader, b[:4]); err != nil {
+			return nil, 0, 0, ErrInvalidStructure
+		}
+		header.Mode = fs.FileMode(binary.LittleEndian.Uint32(b))
+	}
+	if flags&flagModTime != 0 {
+		buffer := make([]byte, 8)
+		if _, err := io.ReadFull(reader, buffer); err != nil {
+			return nil, 0, 0, ErrInvalidStructure
+		}
+		header.ModTime = time.Unix(0, int64(binary.LittleEndian.Uint64(buffer)))
+	}
+	return header, method, version, nil
+}
+
+// ReadHeader reads the file header from the beginning of an encoded stream.
+// It returns a nil header for streams written without one.
+func ReadHeader(reader io.Reader) (*Header, error) {
+	bufferedReader := bufio.NewReader(reader)
+	ok, err := hasFileHeader(bufferedReader)
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrInvalidStructure
+		}
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	header, _, _, err := readFileHeader(bufferedReader)
+	return header, err
+}
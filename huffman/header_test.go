@@ -0,0 +1,202 @@
+package huffman
+
+import (
+	"bytes"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileHeader(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		header := &Header{
+			Name:    "input.txt",
+			Mode:    0755,
+			ModTime: time.Unix(1700000000, 123456789),
+		}
+		buf := &bytes.Buffer{}
+		if err := writeFileHeader(buf, header, methodHuffman); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectedSize := 5 + 2 + len(header.Name) + 4 + 8
+		if buf.Len() != expectedSize {
+			t.Fatalf("invalid header size, expected: %d, got: %d", expectedSize, buf.Len())
+		}
+		if size, err := header.size(); err != nil || size != uint64(expectedSize) {
+			t.Fatalf("invalid computed header size, expected: %d, got: %d, %v", expectedSize, size, err)
+		}
+		result, method, version, err := readFileHeader(buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if method != methodHuffman || version != formatVersion {
+			t.Fatalf("invalid method or version, expected: %d, %d, got: %d, %d", methodHuffman, formatVersion, method, version)
+		}
+		if result.Name != header.Name || result.Mode != header.Mode || !result.ModTime.Equal(header.ModTime) {
+			t.Fatalf("invalid header, expected: %+v, got: %+v", header, result)
+		}
+	})
+
+	t.Run("no fields", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		if err := writeFileHeader(buf, &Header{}, methodHuffman); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(buf.Bytes(), []byte{'H', 'F', formatVersion, methodHuffman, 0}) {
+			t.Fatalf("invalid empty header: %v", buf.Bytes())
+		}
+		if size, err := (&Header{}).size(); err != nil || size != uint64(buf.Len()) {
+			t.Fatalf("invalid computed header size, expected: %d, got: %d, %v", buf.Len(), size, err)
+		}
+		result, _, _, err := readFileHeader(buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Name != "" || result.Mode != 0 || !result.ModTime.IsZero() {
+			t.Fatalf("expected empty header, got: %+v", result)
+		}
+	})
+
+	t.Run("name too long", func(t *testing.T) {
+		header := &Header{Name: strings.Repeat("a", 1<<16)}
+		if err := writeFileHeader(&bytes.Buffer{}, header, methodHuffman); err == nil {
+			t.Fatal("expected error, got: <nil>")
+		}
+		if _, err := header.size(); err == nil {
+			t.Fatal("expected size error, got: <nil>")
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		header := &Header{Name: "file", Mode: fs.ModePerm}
+		if err := writeFileHeader(buf, header, methodHuffman); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data := buf.Bytes()
+		for i := range len(data) {
+			if _, _, _, err := readFileHeader(bytes.NewReader(data[:i])); err != ErrInvalidStructure {
+				t.Fatalf("expected ErrInvalidStructure for %d bytes, got: %v", i, err)
+			}
+		}
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		for _, version := range []byte{0, formatVersion + 1} {
+			data := []byte{'H', 'F', version, methodHuffman, 0}
+			if _, _, _, err := readFileHeader(bytes.NewReader(data)); err != ErrUnsupportedFormat {
+				t.Fatalf("version %d: expected ErrUnsupportedFormat, got: %v", version, err)
+			}
+		}
+	})
+
+	t.Run("previous version", func(t *testing.T) {
+		data := []byte{'H', 'F', legacyFormatVersion, methodStatic, 0}
+		if _, method, version, err := readFileHeader(bytes.NewReader(data)); err != nil || method != methodStatic || version != legacyFormatVersion {
+			t.Fatalf("unexpected result: %d, %d, %v", method, version, err)
+		}
+	})
+}
+
+func TestReadHeader(t *testing.T) {
+	t.Run("legacy stream", func(t *testing.T) {
+		header, err := ReadHeader(bytes.NewReader([]byte{9: 0}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if header != nil {
+			t.Fatalf("expected <nil> header, got: %+v", header)
+		}
+	})
+
+	t.Run("empty stream", func(t *testing.T) {
+		if _, err := ReadHeader(&bytes.Buffer{}); err != ErrInvalidStructure {
+			t.Fatalf("expected ErrInvalidStructure, got: %v", err)
+		}
+	})
+
+	t.Run("encoded stream", func(t *testing.T) {
+		writer := &bytes.Buffer{}
+		options := EncoderOptions{Header: &Header{Name: "a.txt"}}
+		encoder := NewEncoderWithOptions(bytes.NewReader([]byte("abc")), writer, options)
+		if err := encoder.Encode(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		header, err := ReadHeader(writer)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if header == nil || header.Name != "a.txt" {
+			t.Fatalf("invalid header: %+v", header)
+		}
+	})
+}
+
+func TestDecodeWithHeader(t *testing.T) {
+	source := []byte("hello, header")
+	header := &Header{Name: "hello.txt", Mode: 0600, ModTime: time.Unix(42, 0)}
+	encoded := &bytes.Buffer{}
+	encoder := NewEncoderWithOptions(bytes.NewReader(source), encoded, EncoderOptions{Header: header})
+	if err := encoder.Encode(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	writer := &bytes.Buffer{}
+	decoder := NewDecoder(encoded, writer)
+	if err := decoder.Decode(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(writer.Bytes(), source) {
+		t.Fatalf("invalid decoded content, expected: %q, got: %q", source, writer.Bytes())
+	}
+	result := decoder.Header()
+	if result == nil || result.Name != header.Name || result.Mode != header.Mode || !result.ModTime.Equal(header.ModTime) {
+		t.Fatalf("invalid header, expected: %+v, got: %+v", header, result)
+	}
+}
+
+func TestFormatVersions(t *testing.T) {
+	source := []byte(strings.Repeat("version two keeps the fields short\n", 100))
+	legacy := &bytes.Buffer{}
+	if err := NewEncoder(bytes.NewReader(source), legacy).Encode(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	current := &bytes.Buffer{}
+	encoder := NewEncoderWithOptions(bytes.NewReader(source), current, EncoderOptions{Header: &Header{}})
+	if err := encoder.Encode(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 5 header bytes, a 2-byte tree size and a 2-byte content length
+	if expected := legacy.Len() + 5 - 10 + 2 + 2; current.Len() != expected {
+		t.Fatalf("invalid size, expected: %d, got: %d", expected, current.Len())
+	}
+
+	// version 1 streams carry the headerless layout after the header
+	previous := append([]byte{'H', 'F', legacyFormatVersion, methodHuffman, 0}, legacy.Bytes()...)
+	for name, encoded := range map[string][]byte{"version 1": previous, "version 2": current.Bytes()} {
+		writer := &bytes.Buffer{}
+		if err := NewDecoder(bytes.NewReader(encoded), writer).Decode(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if !bytes.Equal(writer.Bytes(), source) {
+			t.Fatalf("%s: invalid decoded content", name)
+		}
+		tree, err := ReadTree(bytes.NewReader(encoded))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if tree.root == nil || tree.root.isLeaf() {
+			t.Fatalf("%s: invalid tree", name)
+		}
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		data := current.Bytes()
+		for _, size := range []int{5, 6, 7, 30} {
+			err := NewDecoder(bytes.NewReader(data[:size]), &bytes.Buffer{}).Decode()
+			if err != ErrInvalidStructure {
+				t.Fatalf("expected ErrInvalidStructure for %d bytes, got: %v", size, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,287 @@
+package huffman
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"slices"
+
+	"github.com/serrhiy/go-huffman/bitio"
+)
+
+const (
+	maxDistance = 1 << 15
+	// decoded data is written out in chunks, keeping the last maxDistance
+	// bytes that back references may point to
+	outputChunkSize = 4 * maxDistance
+)
+
+func fixedTables() (*CodeTable, *CodeTable) {
+	lengths := make([]uint8, 288)
+	for symbol := range lengths {
+		switch {
+		case symbol < 144:
+			lengths[symbol] = 8
+		case symbol < 256:
+			lengths[symbol] = 9
+		case symbol < 280:
+			lengths[symbol] = 7
+		default:
+			lengths[symbol] = 8
+		}
+	}
+	literals, _ := NewCodeTableFromLengths(lengths)
+	distances, _ := NewCodeTableFromLengths(slices.Repeat([]uint8{5}, 30))
+	return literals, distances
+}
+
+type inflater struct {
+	reader      *bitio.Reader
+	writer      *bufio.Writer
+	output      []byte
+	reuseTables bool
+	literals    *CodeTable
+	distances   *CodeTable
+	ctx         context.Context
+}
+
+// reset makes the inflater start a new stream read from reader.
+func (inflater *inflater) reset(ctx context.Context, reader *bufio.Reader, writer *bufio.Writer, reuseTables bool) {
+	inflater.reader.Reset(reader)
+	inflater.writer = writer
+	inflater.output = inflater.output[:0]
+	inflater.reuseTables = reuseTables
+	inflater.literals, inflater.distances = nil, nil
+	inflater.ctx = ctx
+}
+
+func (inflater *inflater) flushOutput(keep int) error {
+	if len(inflater.output) <= keep {
+		return nil
+	}
+	size := len(inflater.output) - keep
+	if _, err := inflater.writer.Write(inflater.output[:size]); err != nil {
+		return err
+	}
+	inflater.output = append(inflater.output[:0], inflater.output[size:]...)
+	return nil
+}
+
+// readBits reads n extra bits; running out of input is always an error in
+// a DEFLATE stream.
+func (inflater *inflater) readBits(n byte) (uint64, error) {
+	value, err := inflater.reader.ReadBitsUint64(n)
+	if err == io.EOF {
+		return 0, ErrInvalidStructure
+	}
+	return value, err
+}
+
+func (inflater *inflater) readSymbol(table *CodeTable) (uint16, error) {
+	symbol, err := table.decode(inflater.reader)
+	if err == io.EOF {
+		return 0, ErrInvalidStructure
+	}
+	return symbol, err
+}
+
+func (inflater *inflater) readTables() (*CodeTable, *CodeTable, error) {
+	header, err := inflater.readBits(14)
+	if err != nil {
+		return nil, nil, err
+	}
+	literalCount := int(header&0x1f) + 257
+	distanceCount := int(header>>5&0x1f) + 1
+	lengthCount := int(header>>10) + 4
+	if literalCount > literalAlphabetSize || distanceCount > distanceAlphabetSize {
+		return nil, nil, ErrInvalidStructure
+	}
+
+	codeLengths := make([]uint8, len(codeLengthOrder))
+	for _, symbol := range codeLengthOrder[:lengthCount] {
+		length, err := inflater.readBits(3)
+		if err != nil {
+			return nil, nil, err
+		}
+		codeLengths[symbol] = uint8(length)
+	}
+	table, err := NewCodeTableFromLengths(codeLengths)
+	if err != nil {
+		return nil, nil, ErrInvalidStructure
+	}
+
+	lengths := make([]uint8, 0, literalCount+distanceCount)
+	for len(lengths) < literalCount+distanceCount {
+		symbol, err := inflater.readSymbol(table)
+		if err != nil {
+			return nil, nil, err
+		}
+		if symbol < 16 {
+			lengths = append(lengths, uint8(symbol))
+			continue
+		}
+		var length uint8 = 0
+		if symbol == 16 {
+			if len(lengths) == 0 {
+				return nil, nil, ErrInvalidStructure
+			}
+			length = lengths[len(lengths)-1]
+		}
+		repeat, err := inflater.readBits(lengthCodeExtraBits[uint8(symbol)])
+		if err != nil {
+			return nil, nil, err
+		}
+		repeat += lengthCodeRepeat[uint8(symbol)]
+		if len(lengths)+int(repeat) > literalCount+distanceCount {
+			return nil, nil, ErrInvalidStructure
+		}
+		for range repeat {
+			lengths = append(lengths, length)
+		}
+	}
+	if lengths[endOfBlock] == 0 {
+		return nil, nil, ErrInvalidStructure
+	}
+	literals, err := NewCodeTableFromLengths(lengths[:literalCount])
+	if err != nil {
+		return nil, nil, ErrInvalidStructure
+	}
+	distances, err := NewCodeTableFromLengths(lengths[literalCount:])
+	if err != nil {
+		return nil, nil, ErrInvalidStructure
+	}
+	return literals, distances, nil
+}
+
+func (inflater *inflater) inflateStored() error {
+	inflater.reader.Align()
+	header, err := inflater.readBits(32)
+	if err != nil {
+		return err
+	}
+	length := header & 0xffff
+	if length != ^(header>>16)&0xffff {
+		return ErrInvalidStructure
+	}
+	for range length {
+		b, err := inflater.readBits(8)
+		if err != nil {
+			return err
+		}
+		inflater.output = append(inflater.output, byte(b))
+	}
+	return inflater.flushOutput(maxDistance)
+}
+
+func (inflater *inflater) inflateBlock(literals, distances *CodeTable) error {
+	for {
+		if len(inflater.output) >= outputChunkSize {
+			if err := inflater.ctx.Err(); err != nil {
+				return err
+			}
+			if err := inflater.flushOutput(maxDistance); err != nil {
+				return err
+			}
+		}
+		symbol, err := inflater.readSymbol(literals)
+		if err != nil {
+			return err
+		}
+		if symbol < endOfBlock {
+			inflater.output = append(inflater.output, byte(symbol))
+			continue
+		}
+		if symbol == endOfBlock {
+			return nil
+		}
+		index := int(symbol) - endOfBlock - 1
+		if index >= len(lengthBase) {
+			return ErrInvalidStructure
+		}
+		extra, err := inflater.readBits(lengthExtraBits[index])
+		if err != nil {
+			return err
+		}
+		length := int(lengthBase[index]) + int(extra)
+
+		symbol, err = inflater.readSymbol(distances)
+		if err != nil {
+			return err
+		}
+		if int(symbol) >= len(distanceBase) {
+			return ErrInvalidStructure
+		}
+		extra, err = inflater.readBits(distanceExtraBits[symbol])
+		if err != nil {
+			return err
+		}
+		distance := int(distanceBase[symbol]) + int(extra)
+		if distance > len(inflater.output) {
+			return ErrInvalidStructure
+		}
+		start := len(inflater.output) - distance
+		for i := range length {
+			inflater.output = append(inflater.output, inflater.output[start+i])
+		}
+	}
+}
+
+// decodeDeflate inflates a DEFLATE stream. With reuseTables it accepts the
+// blocks of the .hfm format that reuse the tables of the last dynamic block.
+func (decoder *HuffmanDecoder) decodeDeflate(reuseTables bool) error {
+	if decoder.inflater == nil {
+		decoder.inflater = &inflater{
+			bitio.NewReaderWithOrder(decoder.reader, bitio.LSBFirst),
+			decoder.writer,
+			make([]byte, 0, outputChunkSize+maxMatch),
+			reuseTables,
+			nil,
+			nil,
+			decoder.ctx,
+		}
+	} else {
+		decoder.inflater.reset(decoder.ctx, decoder.reader, decoder.writer, reuseTables)
+	}
+	inflater := decoder.inflater
+	for {
+		if err := decoder.ctx.Err(); err != nil {
+			return err
+		}
+		header, err := inflater.readBits(3)
+		if err != nil {
+			return err
+		}
+		switch header >> 1 {
+		case blockStored:
+			err = inflater.inflateStored()
+		case blockFixed:
+			err = inflater.inflateBlock(fixedLiterals, fixedDistances)
+		case blockDynamic:
+			literals, distances, tablesErr := inflater.readTables()
+			if tablesErr != nil {
+				return tablesErr
+			}
+			inflater.literals, inflater.distances = literals, distances
+			err = inflater.inflateBlock(literals, distances)
+		case blockReuse:
+			if !inflater.reuseTables || inflater.literals == nil {
+				return ErrInvalidStructure
+			}
+			err = inflater.inflateBlock(inflater.literals, inflater.distances)
+		}
+		if err != nil {
+			return err
+		}
+		if header&1 == 1 {
+			break
+		}
+	}
+	if err := inflater.flushOutput(0); err != nil {
+		return err
+	}
+	if err := decoder.writer.Flush(); err != nil {
+		return err
+	}
+	decoder.progress.done()
+	return nil
+}
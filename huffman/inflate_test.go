@@ -0,0 +1,63 @@
+package huffman
+
+import (
+	"bytes"
+	"compress/flate"
+	"testing"
+
+	"github.com/serrhiy/go-huffman/benchkit"
+)
+
+func inflate(data []byte) ([]byte, error) {
+	writer := &bytes.Buffer{}
+	decoder := NewDecoderWithOptions(bytes.NewReader(data), writer, DecoderOptions{RawDeflate: true})
+	err := decoder.Decode()
+	return writer.Bytes(), err
+}
+
+func TestInflate(t *testing.T) {
+	inputs := map[string][]byte{
+		"empty":  {},
+		"text":   []byte(benchkit.Text(5 * outputChunkSize)),
+		"random": []byte(benchkit.Random(100000)),
+		"range":  []byte(benchkit.Range(0, 256)),
+	}
+	levels := []int{flate.NoCompression, flate.HuffmanOnly, flate.BestSpeed, flate.DefaultCompression, flate.BestCompression}
+	for name, source := range inputs {
+		for _, level := range levels {
+			buffer := &bytes.Buffer{}
+			writer, _ := flate.NewWriter(buffer, level)
+			writer.Write(source)
+			writer.Close()
+			result, err := inflate(buffer.Bytes())
+			if err != nil {
+				t.Fatalf("%s, level %d: unexpected error: %v", name, level, err)
+			}
+			if !bytes.Equal(result, source) {
+				t.Fatalf("%s, level %d: invalid decoded content", name, level)
+			}
+		}
+	}
+}
+
+func TestInflateInvalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"reserved block type", []byte{0b111}},
+		{"stored length mismatch", []byte{0b001, 0x05, 0x00, 0x00, 0x00}},
+		{"stored truncated", []byte{0b001, 0x05, 0x00, 0xfa, 0xff, 'a'}},
+		// fixed block, match of length 3 at distance 1 with nothing decoded
+		{"distance too far", []byte{0b00000011, 0b00000010, 0b00000000}},
+		{"missing final block", []byte{0b010, 0x00}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := inflate(tc.data); err != ErrInvalidStructure {
+				t.Fatalf("expected ErrInvalidStructure, got: %v", err)
+			}
+		})
+	}
+}
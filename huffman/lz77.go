@@ -0,0 +1,202 @@
+package huffman
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+const (
+	minMatch      = 3
+	maxMatch      = 258
+	maxWindow     = 1 << 15
+	minWindow     = 1 << 8
+	hashBits      = 15
+	defaultLZ77   = 6
+	maxLZ77Level  = 9
+	noHashedMatch = -1
+)
+
+// LZ77Options configures the LZ77 match finder. Matched strings are coded
+// as DEFLATE length/distance pairs with their own Huffman tables.
+type LZ77Options struct {
+	// Window is the maximum distance of a match, a power of two between 256
+	// and 32768. Zero selects 32768.
+	Window int
+	// Level trades speed for ratio, from 1 (fastest) to 9 (smallest output).
+	// Zero selects the level of EncoderOptions.Level, or 6 without it.
+	Level int
+}
+
+// matchLevel limits the work done per position: chain is the number of hash
+// chain entries examined, matches of nice bytes end the search, and matches
+// shorter than lazy are checked against a match starting at the next byte.
+type matchLevel struct {
+	chain int
+	nice  int
+	lazy  int
+}
+
+var matchLevels = [maxLZ77Level + 1]matchLevel{
+	1: {4, 8, 0},
+	2: {8, 16, 0},
+	3: {32, 32, 0},
+	4: {16, 16, 4},
+	5: {32, 32, 16},
+	6: {128, 128, 16},
+	7: {256, 128, 32},
+	8: {1024, 258, 128},
+	9: {4096, 258, 258},
+}
+
+// lzToken is a literal byte when length is zero and a back reference of
+// length bytes at the given distance otherwise.
+type lzToken struct {
+	length   uint16
+	distance uint16
+	literal  byte
+}
+
+func literalTokens(data []byte, tokens []lzToken) []lzToken {
+	for _, b := range data {
+		tokens = append(tokens, lzToken{0, 0, b})
+	}
+	return tokens
+}
+
+// matchFinder looks for matches through hash chains of 3-byte prefixes.
+// Positions are counted from the beginning of the input, data holds the
+// last window bytes before the current block followed by the block.
+type matchFinder struct {
+	window   int
+	level    matchLevel
+	data     []byte
+	base     int
+	inserted int
+	head     []int
+	prev     []int
+}
+
+func newMatchFinder(options LZ77Options) (*matchFinder, error) {
+	window, level := options.Window, options.Level
+	if window == 0 {
+		window = maxWindow
+	}
+	if window < minWindow || window > maxWindow || bits.OnesCount(uint(window)) != 1 {
+		return nil, fmt.Errorf("invalid LZ77 window: %d", options.Window)
+	}
+	if level == 0 {
+		level = defaultLZ77
+	}
+	if level < 1 || level > maxLZ77Level {
+		return nil, fmt.Errorf("invalid LZ77 level: %d", options.Level)
+	}
+	head := make([]int, 1<<hashBits)
+	for i := range head {
+		head[i] = noHashedMatch
+	}
+	return &matchFinder{window, matchLevels[level], nil, 0, 0, head, make([]int, window)}, nil
+}
+
+// reset forgets all data, so the finder can be used for another input.
+// Stale entries of prev are never reached through the cleared head.
+func (finder *matchFinder) reset() {
+	finder.data = finder.data[:0]
+	finder.base, finder.inserted = 0, 0
+	for i := range finder.head {
+		finder.head[i] = noHashedMatch
+	}
+}
+
+func (finder *matchFinder) hash(index int) int {
+	data := finder.data[index : index+minMatch]
+	value := uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+	return int((value * 2654435761) >> (32 - hashBits))
+}
+
+// insertUntil adds every position before index to the hash chains.
+func (finder *matchFinder) insertUntil(index int) {
+	last := min(index, len(finder.data)-minMatch+1)
+	for ; finder.inserted < last; finder.inserted++ {
+		position := finder.base + finder.inserted
+		hash := finder.hash(finder.inserted)
+		finder.prev[position&(finder.window-1)] = finder.head[hash]
+		finder.head[hash] = position
+	}
+}
+
+// search returns the longest match at index that is longer than best, or a
+// zero length if there is none.
+func (finder *matchFinder) search(index int, best int) (int, int) {
+	finder.insertUntil(index)
+	limit := min(maxMatch, len(finder.data)-index)
+	best = max(best, minMatch-1)
+	if best >= limit {
+		return 0, 0
+	}
+	data := finder.data
+	position := finder.base + index
+	length, distance := 0, 0
+	candidate := finder.head[finder.hash(index)]
+	for chain := finder.level.chain; chain > 0 && candidate >= 0; chain-- {
+		if position-candidate > finder.window {
+			break
+		}
+		start := candidate - finder.base
+		if data[start+best] == data[index+best] && data[start] == data[index] {
+			n := 1
+			for n < limit && data[start+n] == data[index+n] {
+				n += 1
+			}
+			if n > best {
+				best, length, distance = n, n, position-candidate
+				if n >= finder.level.nice || n == limit {
+					break
+				}
+			}
+		}
+		candidate = finder.prev[candidate&(finder.window-1)]
+	}
+	return length, distance
+}
+
+// slide drops the data that is out of reach of the next block.
+func (finder *matchFinder) slide() {
+	if len(finder.data) <= finder.window {
+		return
+	}
+	offset := len(finder.data) - finder.window
+	finder.data = append(finder.data[:0], finder.data[offset:]...)
+	finder.base += offset
+	// a match longer than the window may have skipped positions that are
+	// now out of reach; they are never hashed
+	finder.inserted = max(finder.inserted-offset, 0)
+}
+
+// tokens appends the tokens of the next block of input to result. Matches
+// may refer to data of previous blocks.
+func (finder *matchFinder) tokens(block []byte, result []lzToken) []lzToken {
+	finder.slide()
+	index := len(finder.data)
+	finder.data = append(finder.data, block...)
+	data := finder.data
+	for index < len(data) {
+		length, distance := finder.search(index, 0)
+		for length > 0 && length < finder.level.lazy && index+1 < len(data) {
+			nextLength, nextDistance := finder.search(index+1, length)
+			if nextLength == 0 {
+				break
+			}
+			result = append(result, lzToken{0, 0, data[index]})
+			index += 1
+			length, distance = nextLength, nextDistance
+		}
+		if length == 0 {
+			result = append(result, lzToken{0, 0, data[index]})
+			index += 1
+			continue
+		}
+		result = append(result, lzToken{uint16(length), uint16(distance), 0})
+		index += length
+	}
+	return result
+}
@@ -0,0 +1,173 @@
+package huffman
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/serrhiy/go-huffman/benchkit"
+)
+
+func applyTokens(t *testing.T, tokens []lzToken, window int) []byte {
+	t.Helper()
+	result := []byte{}
+	for _, token := range tokens {
+		if token.length == 0 {
+			result = append(result, token.literal)
+			continue
+		}
+		distance := int(token.distance)
+		if token.length < minMatch || token.length > maxMatch {
+			t.Fatalf("invalid match length: %d", token.length)
+		}
+		if distance < 1 || distance > window || distance > len(result) {
+			t.Fatalf("invalid match distance: %d", distance)
+		}
+		start := len(result) - distance
+		for i := range int(token.length) {
+			result = append(result, result[start+i])
+		}
+	}
+	return result
+}
+
+func TestMatchFinder(t *testing.T) {
+	inputs := map[string][]byte{
+		"empty":     {},
+		"short":     []byte("ab"),
+		"repeating": bytes.Repeat([]byte("a"), 1000),
+		"overlap":   []byte(strings.Repeat("abc", 200) + "x" + strings.Repeat("abc", 200)),
+		"text":      []byte(benchkit.Text(20000)),
+		"random":    []byte(benchkit.Random(5000)),
+	}
+	for name, source := range inputs {
+		for level := 1; level <= maxLZ77Level; level++ {
+			for _, window := range []int{minWindow, maxWindow} {
+				for _, blockSize := range []int{1000, compressionLevels[0].blockSize} {
+					finder, err := newMatchFinder(LZ77Options{window, level})
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					tokens := []lzToken{}
+					for start := 0; start < len(source); start += blockSize {
+						tokens = finder.tokens(source[start:min(start+blockSize, len(source))], tokens)
+					}
+					if result := applyTokens(t, tokens, window); !bytes.Equal(result, source) {
+						t.Fatalf("%s, level %d, window %d: tokens do not reproduce the input", name, level, window)
+					}
+				}
+			}
+		}
+	}
+
+	t.Run("match across a block boundary", func(t *testing.T) {
+		// a match longer than the smallest window ends past the block, so
+		// sliding the window drops positions that were never hashed
+		source := append(bytes.Repeat([]byte("a"), 3*maxMatch), "bcd"...)
+		finder, err := newMatchFinder(LZ77Options{Window: minWindow, Level: 1})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var tokens []lzToken
+		for start := 0; start < len(source); start += 2 * maxMatch {
+			tokens = finder.tokens(source[start:min(start+2*maxMatch, len(source))], tokens)
+		}
+		if result := applyTokens(t, tokens, minWindow); !bytes.Equal(result, source) {
+			t.Fatal("tokens do not reproduce the input")
+		}
+
+		encoded := &bytes.Buffer{}
+		options := EncoderOptions{LZ77: &LZ77Options{Window: minWindow}}
+		input := bytes.Repeat([]byte{'x'}, 1<<18)
+		if err := NewEncoderWithOptions(bytes.NewReader(input), encoded, options).Encode(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("finds matches", func(t *testing.T) {
+		finder, _ := newMatchFinder(LZ77Options{})
+		tokens := finder.tokens(bytes.Repeat([]byte("abcd"), 100), nil)
+		if len(tokens) > 10 {
+			t.Fatalf("expected a few tokens for a repeating input, got: %d", len(tokens))
+		}
+	})
+}
+
+func TestNewMatchFinder(t *testing.T) {
+	for _, options := range []LZ77Options{{Window: 100}, {Window: 3000}, {Window: 1 << 16}, {Level: -1}, {Level: 10}} {
+		if _, err := newMatchFinder(options); err == nil {
+			t.Fatalf("expected error for options %+v", options)
+		}
+	}
+}
+
+func TestLZ77(t *testing.T) {
+	inputs := map[string][]byte{
+		"empty":  {},
+		"single": []byte("a"),
+		"text":   []byte(benchkit.Text(3*compressionLevels[0].blockSize + 100)),
+		"random": []byte(benchkit.Random(10000)),
+		"log":    []byte(strings.Repeat("INFO request handled in 12ms\n", 2000)),
+	}
+	for name, source := range inputs {
+		t.Run(name, func(t *testing.T) {
+			encoded := encodeStatic(t, source, EncoderOptions{LZ77: &LZ77Options{}})
+			writer := &bytes.Buffer{}
+			if err := NewDecoder(bytes.NewReader(encoded), writer).Decode(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(writer.Bytes(), source) {
+				t.Fatal("invalid decoded content")
+			}
+
+			raw := encodeStatic(t, source, EncoderOptions{LZ77: &LZ77Options{}, RawDeflate: true})
+			decoded, err := io.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+			if err != nil {
+				t.Fatalf("compress/flate rejected the stream: %v", err)
+			}
+			if !bytes.Equal(decoded, source) {
+				t.Fatal("invalid content decoded by compress/flate")
+			}
+		})
+	}
+
+	t.Run("smaller than order-0", func(t *testing.T) {
+		source := inputs["log"]
+		plain := encodeStatic(t, source, EncoderOptions{RawDeflate: true})
+		encoded := encodeStatic(t, source, EncoderOptions{LZ77: &LZ77Options{}})
+		if len(encoded)*10 > len(plain) {
+			t.Fatalf("expected LZ77 output much smaller than %d bytes, got: %d", len(plain), len(encoded))
+		}
+	})
+
+	t.Run("header", func(t *testing.T) {
+		options := EncoderOptions{LZ77: &LZ77Options{}, Header: &Header{Name: "app.log"}}
+		encoded := encodeStatic(t, []byte("log line\nlog line\n"), options)
+		decoder := NewDecoder(bytes.NewReader(encoded), &bytes.Buffer{})
+		if err := decoder.Decode(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if decoder.Header() == nil || decoder.Header().Name != "app.log" {
+			t.Fatalf("invalid header: %+v", decoder.Header())
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		options := EncoderOptions{LZ77: &LZ77Options{Window: 1000}}
+		if err := NewEncoderWithOptions(bytes.NewReader(nil), &bytes.Buffer{}, options).Encode(); err == nil {
+			t.Fatal("expected error, got: <nil>")
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		encoded := encodeStatic(t, inputs["log"], EncoderOptions{LZ77: &LZ77Options{}})
+		for _, size := range []int{0, 3, 5, len(encoded) / 2, len(encoded) - 1} {
+			err := NewDecoder(bytes.NewReader(encoded[:size]), &bytes.Buffer{}).Decode()
+			if err != ErrInvalidStructure {
+				t.Fatalf("expected ErrInvalidStructure for %d bytes, got: %v", size, err)
+			}
+		}
+	})
+}
@@ -1,10 +1,13 @@
 package huffman
 
+// order breaks ties between nodes of equal count: leaves are ordered by
+// symbol and precede internal nodes, which are ordered by creation.
 type node struct {
-	char  byte
+	char  uint16
 	count uint
 	left  *node
 	right *node
+	order int
 }
 
 func (n node) isLeaf() bool {
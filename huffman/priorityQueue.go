@@ -7,7 +7,10 @@ func (pq priorityQueue) Len() int {
 }
 
 func (pq priorityQueue) Less(i, j int) bool {
-	return pq[i].count < pq[j].count
+	if pq[i].count != pq[j].count {
+		return pq[i].count < pq[j].count
+	}
+	return pq[i].order < pq[j].order
 }
 
 func (pq priorityQueue) Swap(i, j int) {
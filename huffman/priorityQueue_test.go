@@ -132,6 +132,23 @@ func TestPriorityQueue(t *testing.T) {
 		}
 	})
 
+	t.Run("equal counts", func(t *testing.T) {
+		var pq priorityQueue
+		heap.Init(&pq)
+
+		heap.Push(&pq, &node{char: 'c', count: 1, order: 'c'})
+		heap.Push(&pq, &node{count: 1, order: 300})
+		heap.Push(&pq, &node{char: 'a', count: 1, order: 'a'})
+		heap.Push(&pq, &node{char: 'b', count: 1, order: 'b'})
+
+		expected := []int{'a', 'b', 'c', 300}
+		for i, order := range expected {
+			if n := heap.Pop(&pq).(*node); n.order != order {
+				t.Fatalf("at pop %d: expected order %d, got %d", i, order, n.order)
+			}
+		}
+	})
+
 	t.Run("pop from empty queue panics", func(t *testing.T) {
 		var pq priorityQueue
 		heap.Init(&pq)
@@ -0,0 +1,84 @@
+package huffman
+
+import "io"
+
+// Progress counts the bytes read and written so far. The plain Huffman mode
+// reads its input twice: Counted is the part read by the first pass, which
+// counts the bytes, and Read the part read while encoding.
+type Progress struct {
+	Read    int64
+	Written int64
+	Counted int64
+}
+
+// ProgressFunc is called every time a chunk of input is consumed and once
+// more when encoding or decoding completes.
+type ProgressFunc func(Progress)
+
+type progressTracker struct {
+	progress Progress
+	report   ProgressFunc
+}
+
+func newProgressTracker(report ProgressFunc) *progressTracker {
+	if report == nil {
+		return nil
+	}
+	return &progressTracker{Progress{}, report}
+}
+
+func (tracker *progressTracker) reader(reader io.Reader) io.Reader {
+	if tracker == nil {
+		return reader
+	}
+	return &progressReader{reader, tracker, &tracker.progress.Read}
+}
+
+// counter wraps the reader of the counting pass.
+func (tracker *progressTracker) counter(reader io.Reader) io.Reader {
+	if tracker == nil {
+		return reader
+	}
+	return &progressReader{reader, tracker, &tracker.progress.Counted}
+}
+
+func (tracker *progressTracker) writer(writer io.Writer) io.Writer {
+	if tracker == nil {
+		return writer
+	}
+	return &progressWriter{writer, tracker}
+}
+
+func (tracker *progressTracker) done() {
+	if tracker != nil {
+		tracker.report(tracker.progress)
+	}
+}
+
+// progressReader adds the bytes it reads to count, a field of the tracked
+// progress.
+type progressReader struct {
+	reader  io.Reader
+	tracker *progressTracker
+	count   *int64
+}
+
+func (reader *progressReader) Read(buffer []byte) (int, error) {
+	readed, err := reader.reader.Read(buffer)
+	if readed > 0 {
+		*reader.count += int64(readed)
+		reader.tracker.report(reader.tracker.progress)
+	}
+	return readed, err
+}
+
+type progressWriter struct {
+	writer  io.Writer
+	tracker *progressTracker
+}
+
+func (writer *progressWriter) Write(buffer []byte) (int, error) {
+	written, err := writer.writer.Write(buffer)
+	writer.tracker.progress.Written += int64(written)
+	return written, err
+}
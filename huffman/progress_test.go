@@ -0,0 +1,92 @@
+package huffman
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/serrhiy/go-huffman/benchkit"
+)
+
+func TestProgress(t *testing.T) {
+	source := []byte(benchkit.Text(1 << 18))
+
+	var encodeReports []Progress
+	encoded := &bytes.Buffer{}
+	options := EncoderOptions{Progress: func(progress Progress) {
+		encodeReports = append(encodeReports, progress)
+	}}
+	encoder := NewEncoderWithOptions(bytes.NewReader(source), encoded, options)
+	if err := encoder.Encode(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decodeReports []Progress
+	decoded := &bytes.Buffer{}
+	encodedSize := int64(encoded.Len())
+	decoder := NewDecoderWithOptions(encoded, decoded, DecoderOptions{Progress: func(progress Progress) {
+		decodeReports = append(decodeReports, progress)
+	}})
+	if err := decoder.Decode(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		reports []Progress
+		read    int64
+		written int64
+		counted int64
+	}{
+		{"encode", encodeReports, int64(len(source)), encodedSize, int64(len(source))},
+		{"decode", decodeReports, encodedSize, int64(len(source)), 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if len(tc.reports) < 2 {
+				t.Fatalf("expected several progress reports, got: %d", len(tc.reports))
+			}
+			for i := 1; i < len(tc.reports); i++ {
+				previous, current := tc.reports[i-1], tc.reports[i]
+				if current.Read < previous.Read || current.Written < previous.Written || current.Counted < previous.Counted {
+					t.Fatalf("progress must not decrease, previous: %+v, current: %+v", previous, current)
+				}
+			}
+			last := tc.reports[len(tc.reports)-1]
+			if last.Read != tc.read || last.Written != tc.written || last.Counted != tc.counted {
+				t.Fatalf("invalid final progress, expected: {%d %d %d}, got: %+v", tc.read, tc.written, tc.counted, last)
+			}
+		})
+	}
+}
+
+func TestProgressCounting(t *testing.T) {
+	source := []byte(benchkit.Text(1 << 18))
+	var reports []Progress
+	options := EncoderOptions{Progress: func(progress Progress) {
+		reports = append(reports, progress)
+	}}
+	if err := NewEncoderWithOptions(bytes.NewReader(source), &bytes.Buffer{}, options).Encode(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	counting := 0
+	for _, report := range reports {
+		if report.Read == 0 && report.Counted > 0 {
+			counting += 1
+		}
+	}
+	if counting < 2 {
+		t.Fatalf("expected several reports while counting, got: %d", counting)
+	}
+}
+
+func TestProgressDisabled(t *testing.T) {
+	if tracker := newProgressTracker(nil); tracker != nil {
+		t.Fatalf("expected <nil> tracker, got: %+v", tracker)
+	}
+	var tracker *progressTracker
+	reader := bytes.NewReader(nil)
+	if tracker.reader(reader) != reader {
+		t.Fatal("disabled tracker must not wrap reader")
+	}
+	tracker.done()
+}
@@ -0,0 +1,202 @@
+package huffman
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// Streams encoded with a caller supplied frequency table (methodStatic) are
+// written in a single pass, so instead of a length field the content is
+// terminated by an end of stream symbol:
+//
+//	shared   byte    1 if the table is omitted
+//	table    CodeTable, or uint32 CRC-32 of it when shared
+//	content  codes, end of stream symbol, zero padding
+const endOfStream = 256
+
+// maxFrequency is the largest count FrequencyMap returns. The counts of all
+// bytes and the end of stream symbol still sum to at most math.MaxUint.
+const maxFrequency = math.MaxUint / 256
+
+var ErrTableMismatch = errors.New("frequency table is missing or does not match the encoded stream")
+
+// FrequencyMap converts a frequency table indexed by byte, as produced by
+// counting into a [256]uint64, to the form of EncoderOptions.Frequencies and
+// DecoderOptions.Frequencies. Bytes with a zero count are left out. When a
+// count exceeds maxFrequency all counts are halved until it fits, keeping
+// non-zero counts at least 1, so that the tree builders can add them up.
+func FrequencyMap(counts *[256]uint64) map[byte]uint {
+	shift := 0
+	for _, count := range counts {
+		for count>>shift > maxFrequency {
+			shift++
+		}
+	}
+	frequencies := make(map[byte]uint, len(counts))
+	for b, count := range counts {
+		if count > 0 {
+			frequencies[byte(b)] = uint(max(count>>shift, 1))
+		}
+	}
+	return frequencies
+}
+
+func staticCodeTable(frequencies map[byte]uint) (*CodeTable, error) {
+	counts := make([]uint, endOfStream+1)
+	for char, count := range frequencies {
+		counts[char] = count
+	}
+	counts[endOfStream] = 1
+	return NewCodeTable(counts)
+}
+
+func tableChecksum(table *CodeTable) uint32 {
+	data, _ := table.MarshalBinary()
+	return crc32.ChecksumIEEE(data)
+}
+
+// staticTable returns the code table of the encoder options and the bytes
+// that precede the content, building them on the first call.
+func (encoder *HuffmanEncoder) staticTable() (*CodeTable, []byte, error) {
+	if encoder.static != nil {
+		return encoder.static, encoder.staticPrefix, nil
+	}
+	table, err := staticCodeTable(encoder.options.Frequencies)
+	if err != nil {
+		return nil, nil, err
+	}
+	b := []byte{0}
+	if encoder.options.SharedTable {
+		b[0] = 1
+		b = binary.LittleEndian.AppendUint32(b, tableChecksum(table))
+	} else {
+		data, _ := table.MarshalBinary()
+		b = append(b, data...)
+	}
+	encoder.static, encoder.staticPrefix = table, b
+	return table, b, nil
+}
+
+func (encoder *HuffmanEncoder) encodeStatic() error {
+	table, b, err := encoder.staticTable()
+	if err != nil {
+		return err
+	}
+	header := encoder.options.Header
+	if header == nil {
+		header = &Header{}
+	}
+	if err := writeFileHeader(encoder.writer, header, methodStatic); err != nil {
+		return err
+	}
+	if _, err := encoder.writer.Write(b); err != nil {
+		return err
+	}
+
+	reader := encoder.inputReader(encoder.progress.reader(encoder.reader))
+	writer := encoder.bitWriter()
+	buffer := encoder.buffer
+	for {
+		if err := encoder.ctx.Err(); err != nil {
+			return err
+		}
+		readed, err := reader.Read(buffer)
+		for i := range readed {
+			if err := table.Encode(writer, uint16(buffer[i])); err != nil {
+				if errors.Is(err, ErrUnknownSymbol) {
+					return fmt.Errorf("byte %#02x is missing from the frequency table: %w", buffer[i], err)
+				}
+				return err
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+	}
+	if err := table.Encode(writer, endOfStream); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	encoder.progress.done()
+	return nil
+}
+
+func (decoder *HuffmanDecoder) readStaticTable() (*CodeTable, error) {
+	shared, err := decoder.reader.ReadByte()
+	if err != nil {
+		return nil, ErrInvalidStructure
+	}
+	switch shared {
+	case 0:
+		table, err := ReadCodeTable(decoder.reader)
+		if err != nil {
+			return nil, err
+		}
+		if table.AlphabetSize() != endOfStream+1 {
+			return nil, ErrInvalidStructure
+		}
+		return table, nil
+	case 1:
+		var b [4]byte
+		if _, err := io.ReadFull(decoder.reader, b[:]); err != nil {
+			return nil, ErrInvalidStructure
+		}
+		if decoder.options.Frequencies == nil {
+			return nil, ErrTableMismatch
+		}
+		if decoder.shared == nil {
+			table, err := staticCodeTable(decoder.options.Frequencies)
+			if err != nil {
+				return nil, err
+			}
+			decoder.shared, decoder.sharedChecksum = table, tableChecksum(table)
+		}
+		if decoder.sharedChecksum != binary.LittleEndian.Uint32(b[:]) {
+			return nil, ErrTableMismatch
+		}
+		return decoder.shared, nil
+	}
+	return nil, ErrInvalidStructure
+}
+
+func (decoder *HuffmanDecoder) decodeStatic() error {
+	table, err := decoder.readStaticTable()
+	if err != nil {
+		return err
+	}
+	reader := decoder.bitReader()
+	for decoded := 0; ; decoded++ {
+		if decoded%bufferSize == 0 {
+			if err := decoder.ctx.Err(); err != nil {
+				return err
+			}
+		}
+		symbol, err := table.Decode(reader)
+		if err != nil {
+			if err == io.EOF {
+				return ErrInvalidStructure
+			}
+			return err
+		}
+		if symbol == endOfStream {
+			break
+		}
+		if err := decoder.writer.WriteByte(byte(symbol)); err != nil {
+			return err
+		}
+	}
+	if err := decoder.writer.Flush(); err != nil {
+		return err
+	}
+	decoder.progress.done()
+	return nil
+}
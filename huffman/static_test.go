@@ -0,0 +1,201 @@
+package huffman
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/serrhiy/go-huffman/benchkit"
+)
+
+type onlyReader struct {
+	reader io.Reader
+}
+
+func (r onlyReader) Read(p []byte) (int, error) {
+	return r.reader.Read(p)
+}
+
+func englishFrequencies() map[byte]uint {
+	frequencies := map[byte]uint{' ': 18, 'e': 12, 't': 9, 'a': 8, 'o': 8, 'i': 7, 'n': 7}
+	for char := range 256 {
+		if _, ok := frequencies[byte(char)]; !ok {
+			frequencies[byte(char)] = 1
+		}
+	}
+	return frequencies
+}
+
+func encodeStatic(t *testing.T, source []byte, options EncoderOptions) []byte {
+	t.Helper()
+	writer := &bytes.Buffer{}
+	encoder := NewEncoderWithOptions(onlyReader{bytes.NewReader(source)}, writer, options)
+	if err := encoder.Encode(); err != nil {
+		t.Fatalf("unexpected error while encoding: %v", err)
+	}
+	return writer.Bytes()
+}
+
+func TestStaticTable(t *testing.T) {
+	source := []byte("the quick brown fox jumps over the lazy dog")
+	frequencies := englishFrequencies()
+
+	t.Run("embedded table", func(t *testing.T) {
+		encoded := encodeStatic(t, source, EncoderOptions{Frequencies: frequencies})
+		writer := &bytes.Buffer{}
+		if err := NewDecoder(bytes.NewReader(encoded), writer).Decode(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(writer.Bytes(), source) {
+			t.Fatalf("invalid decoded content: %q", writer.Bytes())
+		}
+	})
+
+	t.Run("shared table", func(t *testing.T) {
+		options := EncoderOptions{Frequencies: frequencies, SharedTable: true}
+		encoded := encodeStatic(t, source, options)
+		embedded := encodeStatic(t, source, EncoderOptions{Frequencies: frequencies})
+		if len(encoded) >= len(embedded) {
+			t.Fatalf("shared table must be smaller, shared: %d, embedded: %d", len(encoded), len(embedded))
+		}
+		writer := &bytes.Buffer{}
+		decoder := NewDecoderWithOptions(bytes.NewReader(encoded), writer, DecoderOptions{Frequencies: frequencies})
+		if err := decoder.Decode(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(writer.Bytes(), source) {
+			t.Fatalf("invalid decoded content: %q", writer.Bytes())
+		}
+	})
+
+	t.Run("missing shared table", func(t *testing.T) {
+		encoded := encodeStatic(t, source, EncoderOptions{Frequencies: frequencies, SharedTable: true})
+		if err := NewDecoder(bytes.NewReader(encoded), &bytes.Buffer{}).Decode(); err != ErrTableMismatch {
+			t.Fatalf("expected ErrTableMismatch, got: %v", err)
+		}
+	})
+
+	t.Run("mismatched shared table", func(t *testing.T) {
+		encoded := encodeStatic(t, source, EncoderOptions{Frequencies: frequencies, SharedTable: true})
+		other := englishFrequencies()
+		other['z'] = 100
+		decoder := NewDecoderWithOptions(bytes.NewReader(encoded), &bytes.Buffer{}, DecoderOptions{Frequencies: other})
+		if err := decoder.Decode(); err != ErrTableMismatch {
+			t.Fatalf("expected ErrTableMismatch, got: %v", err)
+		}
+	})
+
+	t.Run("symbol missing from table", func(t *testing.T) {
+		options := EncoderOptions{Frequencies: map[byte]uint{'a': 1, 'b': 1}}
+		encoder := NewEncoderWithOptions(bytes.NewReader([]byte("abc")), &bytes.Buffer{}, options)
+		if err := encoder.Encode(); !errors.Is(err, ErrUnknownSymbol) {
+			t.Fatalf("expected ErrUnknownSymbol, got: %v", err)
+		}
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		encoded := encodeStatic(t, nil, EncoderOptions{Frequencies: map[byte]uint{}})
+		writer := &bytes.Buffer{}
+		if err := NewDecoder(bytes.NewReader(encoded), writer).Decode(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if writer.Len() != 0 {
+			t.Fatalf("expected empty output, got: %q", writer.Bytes())
+		}
+	})
+
+	t.Run("header", func(t *testing.T) {
+		options := EncoderOptions{Frequencies: frequencies, Header: &Header{Name: "fox.txt"}}
+		encoded := encodeStatic(t, source, options)
+		decoder := NewDecoder(bytes.NewReader(encoded), &bytes.Buffer{})
+		if err := decoder.Decode(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if decoder.Header() == nil || decoder.Header().Name != "fox.txt" {
+			t.Fatalf("invalid header: %+v", decoder.Header())
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		encoded := encodeStatic(t, source, EncoderOptions{Frequencies: frequencies})
+		for size := range len(encoded) {
+			err := NewDecoder(bytes.NewReader(encoded[:size]), &bytes.Buffer{}).Decode()
+			if err != ErrInvalidStructure {
+				t.Fatalf("expected ErrInvalidStructure for %d bytes, got: %v", size, err)
+			}
+		}
+	})
+
+	t.Run("large input", func(t *testing.T) {
+		source := []byte(benchkit.Random(1 << 16))
+		encoded := encodeStatic(t, source, EncoderOptions{Frequencies: frequencies})
+		writer := &bytes.Buffer{}
+		if err := NewDecoder(bytes.NewReader(encoded), writer).Decode(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(writer.Bytes(), source) {
+			t.Fatal("invalid decoded content")
+		}
+	})
+}
+
+func TestFrequencyMap(t *testing.T) {
+	var counts [256]uint64
+	for b, count := range englishFrequencies() {
+		counts[b] = uint64(count)
+	}
+	counts[0] = 0
+	frequencies := FrequencyMap(&counts)
+	if _, ok := frequencies[0]; ok || len(frequencies) != 255 || frequencies['e'] != 12 {
+		t.Fatalf("invalid frequency map: %v", frequencies)
+	}
+
+	source := []byte("the quick brown fox jumps over the lazy dog")
+	fromMap := englishFrequencies()
+	delete(fromMap, 0)
+	expected := encodeStatic(t, source, EncoderOptions{Frequencies: fromMap, SharedTable: true})
+	encoded := encodeStatic(t, source, EncoderOptions{Frequencies: frequencies, SharedTable: true})
+	if !bytes.Equal(encoded, expected) {
+		t.Fatalf("expected: %x, got: %x", expected, encoded)
+	}
+	output := &bytes.Buffer{}
+	decoder := NewDecoderWithOptions(bytes.NewReader(encoded), output, DecoderOptions{Frequencies: FrequencyMap(&counts)})
+	if err := decoder.Decode(); err != nil || !bytes.Equal(output.Bytes(), source) {
+		t.Fatalf("expected: %q, got: %q, %v", source, output.Bytes(), err)
+	}
+
+	t.Run("large counts", func(t *testing.T) {
+		var counts [256]uint64
+		for b := range counts {
+			counts[b] = math.MaxUint64 - uint64(b)
+		}
+		counts['a'] = math.MaxUint64 / 2
+		counts['b'] = 1
+		frequencies := FrequencyMap(&counts)
+		var sum uint64
+		for _, count := range frequencies {
+			if count > maxFrequency {
+				t.Fatalf("count %d exceeds %d", count, uint64(maxFrequency))
+			}
+			sum += uint64(count)
+		}
+		if sum > math.MaxUint-1 || frequencies['a'] >= frequencies['c'] || frequencies['b'] != 1 {
+			t.Fatalf("invalid frequency map: %v", frequencies)
+		}
+		source := []byte("abcabcabc")
+		encoded := encodeStatic(t, source, EncoderOptions{Frequencies: frequencies})
+		output := &bytes.Buffer{}
+		if err := NewDecoder(bytes.NewReader(encoded), output).Decode(); err != nil || !bytes.Equal(output.Bytes(), source) {
+			t.Fatalf("expected: %q, got: %q, %v", source, output.Bytes(), err)
+		}
+	})
+}
+
+func TestNotSeekable(t *testing.T) {
+	encoder := NewEncoder(onlyReader{bytes.NewReader([]byte("abc"))}, &bytes.Buffer{})
+	if err := encoder.Encode(); err != ErrNotSeekable {
+		t.Fatalf("expected ErrNotSeekable, got: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package huffman
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/serrhiy/go-huffman/bitio"
+	"github.com/serrhiy/go-huffman/internal/symbols"
+)
+
+// Tree is a read-only view of a Huffman tree used for inspection. Trees read
+// from an encoded stream carry no symbol counts.
+type Tree struct {
+	root      *node
+	hasCounts bool
+}
+
+type treeNode struct {
+	Symbol *byte     `json:"symbol,omitempty"`
+	Count  *uint     `json:"count,omitempty"`
+	Code   string    `json:"code"`
+	Left   *treeNode `json:"left,omitempty"`
+	Right  *treeNode `json:"right,omitempty"`
+}
+
+func NewTree(frequencies map[byte]uint) *Tree {
+	return &Tree{buildTree(frequencies), true}
+}
+
+func BuildTree(reader io.Reader) (*Tree, error) {
+	frequencies, err := getFrequencyMap(reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewTree(frequencies), nil
+}
+
+// ReadTree parses the tree stored in the header of an encoded stream.
+func ReadTree(reader io.Reader) (*Tree, error) {
+	bufferedReader := bufio.NewReader(reader)
+	ok, err := hasFileHeader(bufferedReader)
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrInvalidStructure
+		}
+		return nil, err
+	}
+	var version byte = legacyFormatVersion
+	if ok {
+		var method byte
+		_, method, version, err = readFileHeader(bufferedReader)
+		if err != nil {
+			return nil, err
+		}
+		if method != methodHuffman {
+			return nil, ErrUnsupportedFormat
+		}
+	}
+	root, err := readTree(bitio.NewReader(bufferedReader), version, &nodeArena{})
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrInvalidStructure
+		}
+		return nil, err
+	}
+	return &Tree{root, false}, nil
+}
+
+func (tree *Tree) toTreeNode(root *node, code string) *treeNode {
+	if root == nil {
+		return nil
+	}
+	result := &treeNode{Code: code}
+	if tree.hasCounts {
+		count := root.count
+		result.Count = &count
+	}
+	if root.isLeaf() {
+		symbol := byte(root.char)
+		result.Symbol = &symbol
+		return result
+	}
+	result.Left = tree.toTreeNode(root.left, code+"1")
+	result.Right = tree.toTreeNode(root.right, code+"0")
+	return result
+}
+
+func (tree *Tree) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tree.toTreeNode(tree.root, ""))
+}
+
+func dotLabel(label string) string {
+	return strconv.Quote(label)
+}
+
+// WriteDot renders the tree in Graphviz DOT format. Edges are labelled with
+// the bit they append to the code.
+func (tree *Tree) WriteDot(writer io.Writer) error {
+	builder := &strings.Builder{}
+	builder.WriteString("digraph huffman {\n")
+	builder.WriteString("\tnode [shape=circle];\n")
+	id := 0
+	var walk func(root *node, code string) int
+	walk = func(root *node, code string) int {
+		current := id
+		id += 1
+		var lines []string
+		if root.isLeaf() {
+			lines = append(lines, symbols.Format(byte(root.char)))
+		}
+		if tree.hasCounts {
+			lines = append(lines, strconv.FormatUint(uint64(root.count), 10))
+		}
+		if root.isLeaf() {
+			lines = append(lines, code)
+			fmt.Fprintf(builder, "\tn%d [shape=box, label=%s];\n", current, dotLabel(strings.Join(lines, "\n")))
+			return current
+		}
+		fmt.Fprintf(builder, "\tn%d [label=%s];\n", current, dotLabel(strings.Join(lines, "\n")))
+		children := []struct {
+			child *node
+			bit   string
+		}{{root.left, "1"}, {root.right, "0"}}
+		for _, edge := range children {
+			if edge.child == nil {
+				continue
+			}
+			child := walk(edge.child, code+edge.bit)
+			fmt.Fprintf(builder, "\tn%d -> n%d [label=%q];\n", current, child, edge.bit)
+		}
+		return current
+	}
+	if tree.root != nil {
+		walk(tree.root, "")
+	}
+	builder.WriteString("}\n")
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
@@ -0,0 +1,192 @@
+package huffman
+
+import (
+	"cmp"
+	"slices"
+)
+
+// TreeBuilder selects the algorithm used to build the Huffman tree. All of them
+// produce optimal codes.
+type TreeBuilder int
+
+const (
+	// HeapTreeBuilder merges nodes through a binary heap, O(n log n).
+	HeapTreeBuilder TreeBuilder = iota
+	// TwoQueueTreeBuilder sorts the leaves once and merges them from two
+	// FIFO queues in linear time. It builds the same tree as the heap.
+	TwoQueueTreeBuilder
+	// InPlaceTreeBuilder computes code lengths in place with the
+	// Moffat-Katajainen algorithm and builds a tree with those lengths.
+	InPlaceTreeBuilder
+)
+
+func frequencySlice(frequencies map[byte]uint) []uint {
+	result := make([]uint, 256)
+	for char, count := range frequencies {
+		result[char] = count
+	}
+	return result
+}
+
+func (builder TreeBuilder) build(frequencies map[byte]uint) *node {
+	switch builder {
+	case TwoQueueTreeBuilder:
+		return buildTreeTwoQueue(frequencySlice(frequencies))
+	case InPlaceTreeBuilder:
+		return buildTreeInPlace(frequencySlice(frequencies))
+	}
+	return buildTree(frequencies)
+}
+
+// buildCounts builds the tree of the byte counts, taking the nodes of the
+// heap builder from arena.
+func (builder TreeBuilder) buildCounts(counts *[256]uint, arena *nodeArena) *node {
+	switch builder {
+	case TwoQueueTreeBuilder:
+		return buildTreeTwoQueue(counts[:])
+	case InPlaceTreeBuilder:
+		return buildTreeInPlace(counts[:])
+	}
+	return arena.symbolTree(counts[:])
+}
+
+func sortedLeaves(frequencies []uint) []*node {
+	leaves := make([]*node, 0, len(frequencies))
+	for symbol, count := range frequencies {
+		if count > 0 {
+			leaves = append(leaves, &node{uint16(symbol), count, nil, nil, symbol})
+		}
+	}
+	slices.SortFunc(leaves, func(a, b *node) int {
+		if a.count != b.count {
+			return cmp.Compare(a.count, b.count)
+		}
+		return cmp.Compare(a.order, b.order)
+	})
+	return leaves
+}
+
+func buildTreeTwoQueue(frequencies []uint) *node {
+	leaves := sortedLeaves(frequencies)
+	if len(leaves) == 0 {
+		return nil
+	}
+	if len(leaves) == 1 {
+		return &node{0, leaves[0].count, leaves[0], nil, maxAlphabetSize}
+	}
+	internal := make([]*node, 0, len(leaves)-1)
+	i, j := 0, 0
+	next := func() *node {
+		if i < len(leaves) && (j >= len(internal) || leaves[i].count <= internal[j].count) {
+			i += 1
+			return leaves[i-1]
+		}
+		j += 1
+		return internal[j-1]
+	}
+	for range len(leaves) - 1 {
+		node1, node2 := next(), next()
+		order := maxAlphabetSize + len(internal)
+		internal = append(internal, &node{0, node1.count + node2.count, node1, node2, order})
+	}
+	return internal[len(internal)-1]
+}
+
+// minimumRedundancy replaces weights sorted in non-decreasing order with the
+// code lengths of an optimal prefix code, see A. Moffat and J. Katajainen,
+// "In-Place Calculation of Minimum-Redundancy Codes". The resulting lengths
+// are non-increasing.
+func minimumRedundancy(weights []uint) {
+	n := len(weights)
+	if n == 0 {
+		return
+	}
+	if n == 1 {
+		weights[0] = 1
+		return
+	}
+
+	// set parent pointers, left to right
+	weights[0] += weights[1]
+	root, leaf := 0, 2
+	for next := 1; next < n-1; next++ {
+		if leaf >= n || weights[root] < weights[leaf] {
+			weights[next] = weights[root]
+			weights[root] = uint(next)
+			root += 1
+		} else {
+			weights[next] = weights[leaf]
+			leaf += 1
+		}
+		if leaf >= n || (root < next && weights[root] < weights[leaf]) {
+			weights[next] += weights[root]
+			weights[root] = uint(next)
+			root += 1
+		} else {
+			weights[next] += weights[leaf]
+			leaf += 1
+		}
+	}
+
+	// set internal node depths, right to left
+	weights[n-2] = 0
+	for next := n - 3; next >= 0; next-- {
+		weights[next] = weights[weights[next]] + 1
+	}
+
+	// set leaf depths, right to left
+	available, used, depth := 1, 0, uint(0)
+	root, next := n-2, n-1
+	for available > 0 {
+		for root >= 0 && weights[root] == depth {
+			used += 1
+			root -= 1
+		}
+		for available > used {
+			weights[next] = depth
+			next -= 1
+			available -= 1
+		}
+		available = 2 * used
+		depth += 1
+		used = 0
+	}
+}
+
+// treeFromLengths builds a tree bottom-up, one level at a time, from leaves
+// ordered by non-increasing code length.
+func treeFromLengths(leaves []*node, lengths []uint) *node {
+	if len(leaves) == 1 {
+		return &node{0, leaves[0].count, leaves[0], nil, maxAlphabetSize}
+	}
+	order := maxAlphabetSize
+	var level []*node
+	i := 0
+	for depth := lengths[0]; depth > 0; depth-- {
+		next := make([]*node, 0, len(level)/2+len(leaves)-i)
+		for i < len(leaves) && lengths[i] == depth {
+			next = append(next, leaves[i])
+			i += 1
+		}
+		for k := 0; k+1 < len(level); k += 2 {
+			left, right := level[k], level[k+1]
+			next = append(next, &node{0, left.count + right.count, left, right, order})
+			order += 1
+		}
+		level = next
+	}
+	return &node{0, level[0].count + level[1].count, level[0], level[1], order}
+}
+
+func buildTreeInPlace(frequencies []uint) *node {
+	leaves := sortedLeaves(frequencies)
+	if len(leaves) == 0 {
+		return nil
+	}
+	lengths := make([]uint, len(leaves))
+	for i, leaf := range leaves {
+		lengths[i] = leaf.count
+	}
+	minimumRedundancy(lengths)
+	return treeFromLengths(leaves, lengths)
+}
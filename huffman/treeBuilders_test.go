@@ -0,0 +1,131 @@
+package huffman
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/serrhiy/go-huffman/benchkit"
+)
+
+func sameTree(a, b *node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.isLeaf() != b.isLeaf() || a.count != b.count {
+		return false
+	}
+	if a.isLeaf() {
+		return a.char == b.char
+	}
+	return sameTree(a.left, b.left) && sameTree(a.right, b.right)
+}
+
+func treeCost(root *node, depth uint) uint {
+	if root == nil {
+		return 0
+	}
+	if root.isLeaf() {
+		return root.count * depth
+	}
+	return treeCost(root.left, depth+1) + treeCost(root.right, depth+1)
+}
+
+func randomFrequencies(random *rand.Rand, size int) []uint {
+	frequencies := make([]uint, size)
+	for i := range frequencies {
+		if random.IntN(4) > 0 {
+			frequencies[i] = uint(random.IntN(1000))
+		}
+	}
+	return frequencies
+}
+
+func TestMinimumRedundancy(t *testing.T) {
+	testCases := []struct {
+		name     string
+		weights  []uint
+		expected []uint
+	}{
+		{"empty", []uint{}, []uint{}},
+		{"single", []uint{7}, []uint{1}},
+		{"pair", []uint{1, 9}, []uint{1, 1}},
+		{"uniform", []uint{1, 1, 1, 1}, []uint{2, 2, 2, 2}},
+		{"fibonacci", []uint{1, 1, 2, 3, 5, 8}, []uint{5, 5, 4, 3, 2, 1}},
+		{"skewed", []uint{1, 2, 4, 4, 20}, []uint{4, 4, 3, 2, 1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			minimumRedundancy(tc.weights)
+			if !slices.Equal(tc.weights, tc.expected) {
+				t.Fatalf("expected lengths %v, got: %v", tc.expected, tc.weights)
+			}
+		})
+	}
+}
+
+func TestTreeBuilders(t *testing.T) {
+	random := rand.New(rand.NewPCG(1, 2))
+
+	t.Run("empty", func(t *testing.T) {
+		if buildTreeTwoQueue(make([]uint, 256)) != nil || buildTreeInPlace(make([]uint, 256)) != nil {
+			t.Fatal("expected <nil> tree for empty frequencies")
+		}
+	})
+
+	t.Run("single symbol", func(t *testing.T) {
+		frequencies := map[byte]uint{'a': 3}
+		expected := buildTree(frequencies)
+		for _, builder := range []TreeBuilder{TwoQueueTreeBuilder, InPlaceTreeBuilder} {
+			if root := builder.build(frequencies); !sameTree(root, expected) {
+				t.Fatalf("builder %d: invalid single symbol tree", builder)
+			}
+		}
+	})
+
+	t.Run("two-queue matches heap", func(t *testing.T) {
+		for range 100 {
+			frequencies := randomFrequencies(random, 1+random.IntN(300))
+			if !sameTree(buildTreeTwoQueue(frequencies), buildSymbolTree(frequencies)) {
+				t.Fatalf("trees differ for frequencies %v", frequencies)
+			}
+		}
+	})
+
+	t.Run("in-place is optimal", func(t *testing.T) {
+		for range 100 {
+			frequencies := randomFrequencies(random, 1+random.IntN(300))
+			expected := buildSymbolTree(frequencies)
+			root := buildTreeInPlace(frequencies)
+			if treeCost(root, 0) != treeCost(expected, 0) {
+				t.Fatalf("suboptimal tree, expected cost: %d, got: %d", treeCost(expected, 0), treeCost(root, 0))
+			}
+			lengths := codeLengths(root, len(frequencies))
+			for symbol, count := range frequencies {
+				if (count > 0) != (lengths[symbol] > 0) {
+					t.Fatalf("symbol %d with count %d has length %d", symbol, count, lengths[symbol])
+				}
+			}
+		}
+	})
+
+	for _, builder := range []TreeBuilder{HeapTreeBuilder, TwoQueueTreeBuilder, InPlaceTreeBuilder} {
+		t.Run("round trip", func(t *testing.T) {
+			for _, source := range []string{"", "a", "hello world", benchkit.Text(5000), benchkit.Random(5000)} {
+				encoded := &bytes.Buffer{}
+				options := EncoderOptions{TreeBuilder: builder}
+				if err := NewEncoderWithOptions(bytes.NewReader([]byte(source)), encoded, options).Encode(); err != nil {
+					t.Fatalf("builder %d: unexpected error: %v", builder, err)
+				}
+				decoded := &bytes.Buffer{}
+				if err := NewDecoder(encoded, decoded).Decode(); err != nil {
+					t.Fatalf("builder %d: unexpected error: %v", builder, err)
+				}
+				if decoded.String() != source {
+					t.Fatalf("builder %d: invalid round trip", builder)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func collectLeaves(root *treeNode, leaves map[byte]*treeNode) {
+	if root == nil {
+		return
+	}
+	if root.Symbol != nil {
+		leaves[*root.Symbol] = root
+		return
+	}
+	collectLeaves(root.Left, leaves)
+	collectLeaves(root.Right, leaves)
+}
+
+func TestTreeJSON(t *testing.T) {
+	t.Run("from frequencies", func(t *testing.T) {
+		frequencies := map[byte]uint{'a': 5, 'b': 2, 'c': 1}
+		tree := NewTree(frequencies)
+		data, err := json.Marshal(tree)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var root treeNode
+		if err := json.Unmarshal(data, &root); err != nil {
+			t.Fatalf("invalid json: %v", err)
+		}
+		if root.Count == nil || *root.Count != 8 {
+			t.Fatalf("invalid root count: %s", data)
+		}
+		leaves := map[byte]*treeNode{}
+		collectLeaves(&root, leaves)
+		codes := buildCodes(tree.root)
+		for char, count := range frequencies {
+			leaf, ok := leaves[char]
+			if !ok {
+				t.Fatalf("missing leaf %q in %s", char, data)
+			}
+			if *leaf.Count != count || leaf.Code != codes[char].String() {
+				t.Fatalf("invalid leaf %q: %+v", char, leaf)
+			}
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		data, err := json.Marshal(NewTree(map[byte]uint{}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(data) != "null" {
+			t.Fatalf("expected null, got: %s", data)
+		}
+	})
+
+	t.Run("from encoded stream", func(t *testing.T) {
+		source := "abbccccdddddddd"
+		encoded := &bytes.Buffer{}
+		options := EncoderOptions{Header: &Header{Name: "river"}}
+		if err := NewEncoderWithOptions(strings.NewReader(source), encoded, options).Encode(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tree, err := ReadTree(encoded)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		built, err := BuildTree(strings.NewReader(source))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		readCodes, builtCodes := buildCodes(tree.root), buildCodes(built.root)
+		if len(readCodes) != len(builtCodes) {
+			t.Fatalf("codes differ, read: %v, built: %v", readCodes, builtCodes)
+		}
+		for char, code := range builtCodes {
+			if read, ok := readCodes[char]; !ok || !read.Equal(code) {
+				t.Fatalf("codes differ, read: %v, built: %v", readCodes, builtCodes)
+			}
+		}
+		data, err := json.Marshal(tree)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.Contains(string(data), "count") {
+			t.Fatalf("tree read from stream must not contain counts: %s", data)
+		}
+	})
+
+	t.Run("invalid stream", func(t *testing.T) {
+		if _, err := ReadTree(&bytes.Buffer{}); err != ErrInvalidStructure {
+			t.Fatalf("expected ErrInvalidStructure, got: %v", err)
+		}
+		if _, err := ReadTree(bytes.NewReader([]byte{10, 0})); err != ErrInvalidStructure {
+			t.Fatalf("expected ErrInvalidStructure, got: %v", err)
+		}
+	})
+}
+
+func TestTreeDot(t *testing.T) {
+	t.Run("from frequencies", func(t *testing.T) {
+		tree := NewTree(map[byte]uint{'a': 3, '\n': 1})
+		out := &bytes.Buffer{}
+		if err := tree.WriteDot(out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		dot := out.String()
+		expected := []string{
+			"digraph huffman {",
+			`n0 [label="4"];`,
+			`[shape=box, label="'a'\n3\n`,
+			`[shape=box, label="0x0a\n1\n`,
+			`n0 -> n1 [label="1"];`,
+			`n0 -> n2 [label="0"];`,
+		}
+		for _, line := range expected {
+			if !strings.Contains(dot, line) {
+				t.Fatalf("expected %q in:\n%s", line, dot)
+			}
+		}
+	})
+
+	t.Run("single symbol", func(t *testing.T) {
+		tree := NewTree(map[byte]uint{'x': 2})
+		out := &bytes.Buffer{}
+		if err := tree.WriteDot(out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.Count(out.String(), "->") != 1 {
+			t.Fatalf("expected a single edge:\n%s", out.String())
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		if err := NewTree(nil).WriteDot(out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.String() != "digraph huffman {\n\tnode [shape=circle];\n}\n" {
+			t.Fatalf("unexpected output:\n%s", out.String())
+		}
+	})
+}
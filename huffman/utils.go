@@ -3,6 +3,7 @@ package huffman
 import (
 	"bufio"
 	"container/heap"
+	"context"
 	"fmt"
 	"io"
 
@@ -10,28 +11,46 @@ import (
 )
 
 func getFrequencyMap(r io.Reader) (map[byte]uint, error) {
+	var counts [256]uint
+	if err := countBytes(context.Background(), bufio.NewReader(r), make([]byte, bufferSize), &counts); err != nil {
+		return nil, err
+	}
 	result := make(map[byte]uint, 1<<7)
-	reader := bufio.NewReader(r)
-	buffer := make([]byte, bufferSize)
+	for b, count := range counts {
+		if count > 0 {
+			result[byte(b)] = count
+		}
+	}
+	return result, nil
+}
+
+// countBytes adds the number of occurrences of every byte of reader to
+// counts, reading them into buffer, until the end of the input or until ctx
+// is done.
+func countBytes(ctx context.Context, reader *bufio.Reader, buffer []byte, counts *[256]uint) error {
 	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		readed, err := reader.Read(buffer)
 		for i := range readed {
-			result[buffer[i]] += 1
+			counts[buffer[i]] += 1
 		}
 		if err != nil {
 			if err == io.EOF {
-				break
+				return nil
 			}
-			return nil, err
+			return err
 		}
 	}
-	return result, nil
 }
 
 func toPriorityQueue(frequencies map[byte]uint) priorityQueue {
 	result := make(priorityQueue, 0, len(frequencies))
+	leaves := make([]node, 0, len(frequencies))
 	for char, count := range frequencies {
-		result = append(result, &node{char, count, nil, nil})
+		leaves = append(leaves, node{uint16(char), count, nil, nil, int(char)})
+		result = append(result, &leaves[len(leaves)-1])
 	}
 	heap.Init(&result)
 	return result
@@ -41,18 +60,91 @@ func buildTree(frequencies map[byte]uint) *node {
 	if len(frequencies) == 0 {
 		return nil
 	}
-	queue := toPriorityQueue(frequencies)
+	arena := &nodeArena{make([]node, 0, 2*len(frequencies)), toPriorityQueue(frequencies)}
+	return arena.merge()
+}
+
+func buildSymbolTree(frequencies []uint) *node {
+	return (&nodeArena{}).symbolTree(frequencies)
+}
+
+// nodeArena allocates the nodes of a tree from one slice and keeps it, along
+// with the queue, for the next tree. Nodes do not change once created, so
+// the nodes of a tree stay valid when the slice grows, until the next tree
+// is built.
+type nodeArena struct {
+	nodes []node
+	queue priorityQueue
+}
+
+func (arena *nodeArena) reset(capacity int) {
+	if cap(arena.nodes) < capacity {
+		arena.nodes = make([]node, 0, capacity)
+	}
+	arena.nodes = arena.nodes[:0]
+	arena.queue = arena.queue[:0]
+}
+
+func (arena *nodeArena) new(value node) *node {
+	arena.nodes = append(arena.nodes, value)
+	return &arena.nodes[len(arena.nodes)-1]
+}
+
+// symbolTree builds the same tree as buildTree over an array of counts.
+func (arena *nodeArena) symbolTree(frequencies []uint) *node {
+	used := 0
+	for _, count := range frequencies {
+		if count > 0 {
+			used += 1
+		}
+	}
+	// a tree of n leaves has n-1 inner nodes
+	arena.reset(2 * used)
+	for symbol, count := range frequencies {
+		if count > 0 {
+			arena.queue = append(arena.queue, arena.new(node{uint16(symbol), count, nil, nil, symbol}))
+		}
+	}
+	if len(arena.queue) == 0 {
+		return nil
+	}
+	heap.Init(&arena.queue)
+	return arena.merge()
+}
+
+// merge combines the nodes of the queue into a tree.
+func (arena *nodeArena) merge() *node {
+	queue := &arena.queue
+	order := maxAlphabetSize
 	if queue.Len() == 1 {
-		left := heap.Pop(&queue).(*node)
-		return &node{0, left.count, left, nil}
+		left := heap.Pop(queue).(*node)
+		return arena.new(node{0, left.count, left, nil, order})
 	}
 	for queue.Len() > 1 {
-		node1 := heap.Pop(&queue).(*node)
-		node2 := heap.Pop(&queue).(*node)
-		combined := &node{0, node1.count + node2.count, node1, node2}
-		heap.Push(&queue, combined)
+		node1 := heap.Pop(queue).(*node)
+		node2 := heap.Pop(queue).(*node)
+		heap.Push(queue, arena.new(node{0, node1.count + node2.count, node1, node2, order}))
+		order += 1
 	}
-	return heap.Pop(&queue).(*node)
+	return heap.Pop(queue).(*node)
+}
+
+func _codeLengths(root *node, depth int, lengths []int) {
+	if root == nil {
+		return
+	}
+	if root.isLeaf() {
+		lengths[root.char] = depth
+		return
+	}
+	_codeLengths(root.left, depth+1, lengths)
+	_codeLengths(root.right, depth+1, lengths)
+}
+
+func codeLengths(root *node, alphabetSize int) []int {
+	lengths := make([]int, alphabetSize)
+	_codeLengths(root, 0, lengths)
+	return lengths
 }
 
 func calculateTreeSize(root *node) uint16 {
@@ -65,37 +157,110 @@ func calculateTreeSize(root *node) uint16 {
 	return 1 + calculateTreeSize(root.left) + calculateTreeSize(root.right)
 }
 
-func calculateContentSize(codes map[byte]string, frequencies map[byte]uint) (uint64, error) {
+func calculateContentSize(codes map[byte]*bitio.BitStream, frequencies map[byte]uint) (uint64, error) {
 	var size uint64 = 0
 	for char, code := range codes {
 		frequency, ok := frequencies[char]
 		if !ok {
 			return 0, fmt.Errorf("char %q exists in codes bit absent in frequency map", char)
 		}
-		size += uint64(frequency * uint(len(code)))
+		size += uint64(frequency) * uint64(code.Len())
 	}
 	return size, nil
 }
 
-func _buildCodes(root *node, prefix string, table map[byte]string) {
+func _buildCodes(root *node, prefix *bitio.BitStream, table map[byte]*bitio.BitStream) {
 	if root == nil {
 		return
 	}
 	if root.left == nil && root.right == nil {
-		table[root.char] = prefix
+		table[byte(root.char)] = prefix
 		return
 	}
-	_buildCodes(root.left, prefix+"1", table)
-	_buildCodes(root.right, prefix+"0", table)
+	left := bitio.BitStreamCopy(prefix)
+	left.Push(1)
+	_buildCodes(root.left, left, table)
+	right := bitio.BitStreamCopy(prefix)
+	right.Push(0)
+	_buildCodes(root.right, right, table)
 }
 
-func buildCodes(root *node) map[byte]string {
-	table := make(map[byte]string, 1<<7)
-	_buildCodes(root, "", table)
+func buildCodes(root *node) map[byte]*bitio.BitStream {
+	table := make(map[byte]*bitio.BitStream, 1<<7)
+	_buildCodes(root, bitio.NewBitStream(), table)
 	return table
 }
 
-func writeCodes(root *node, writer *bitio.Writer) error {
+type codeWord struct {
+	bits   uint64
+	length byte
+}
+
+// codeWords fills codes with the code of every leaf of root, or returns
+// false if one of them does not fit into 64 bits.
+func codeWords(root *node, bits uint64, length byte, codes *[256]codeWord) bool {
+	if root == nil {
+		return true
+	}
+	if root.isLeaf() {
+		codes[root.char] = codeWord{bits, length}
+		return true
+	}
+	if length == 64 {
+		return false
+	}
+	return codeWords(root.left, bits<<1|1, length+1, codes) &&
+		codeWords(root.right, bits<<1, length+1, codes)
+}
+
+// codeBook holds the code of every byte of a tree as a word. Codes longer
+// than 64 bits, which need more than 10^13 bytes of input, are kept as bit
+// streams instead.
+type codeBook struct {
+	words   [256]codeWord
+	streams map[byte]*bitio.BitStream
+}
+
+func (book *codeBook) build(root *node) {
+	book.words, book.streams = [256]codeWord{}, nil
+	if !codeWords(root, 0, 0, &book.words) {
+		book.streams = buildCodes(root)
+	}
+}
+
+func (book *codeBook) length(b byte) uint64 {
+	if book.streams != nil {
+		return uint64(book.streams[b].Len())
+	}
+	return uint64(book.words[b].length)
+}
+
+// contentSize returns the number of bits of the codes of the counted bytes.
+func (book *codeBook) contentSize(counts *[256]uint) uint64 {
+	var size uint64 = 0
+	for b, count := range counts {
+		if count > 0 {
+			size += uint64(count) * book.length(byte(b))
+		}
+	}
+	return size
+}
+
+func (book *codeBook) write(writer *bitio.Writer, b byte) error {
+	if book.streams != nil {
+		_, err := book.streams[b].WriteTo(writer)
+		return err
+	}
+	return writer.WriteBitsUint64(book.words[b].bits, book.words[b].length)
+}
+
+// treeWriter is implemented by bitio.Writer and sliceBitWriter.
+type treeWriter interface {
+	WriteBit(bit byte) error
+	WriteByte(b byte) error
+}
+
+func writeCodes(root *node, writer treeWriter) error {
 	if root == nil {
 		return nil
 	}
@@ -103,7 +268,7 @@ func writeCodes(root *node, writer *bitio.Writer) error {
 		if err := writer.WriteBit(1); err != nil {
 			return err
 		}
-		if err := writer.WriteByte(root.char); err != nil {
+		if err := writer.WriteByte(byte(root.char)); err != nil {
 			return err
 		}
 		return nil
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"container/heap"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
@@ -160,7 +161,7 @@ func TestToPriorityQueue(t *testing.T) {
 		}
 
 		expectedOrder := []struct {
-			char  byte
+			char  uint16
 			count uint
 		}{
 			{'d', 1},
@@ -263,6 +264,19 @@ func TestBuildTree(t *testing.T) {
 	})
 }
 
+// codeStreams converts codes written as strings of '0' and '1'.
+func codeStreams(codes map[byte]string) map[byte]*bitio.BitStream {
+	result := make(map[byte]*bitio.BitStream, len(codes))
+	for char, code := range codes {
+		stream := bitio.NewBitStream()
+		for _, bit := range code {
+			stream.Push(byte(bit - '0'))
+		}
+		result[char] = stream
+	}
+	return result
+}
+
 func TestBuildCodes(t *testing.T) {
 	t.Run("empty", func(t *testing.T) {
 		if codes := buildCodes(nil); len(codes) != 0 {
@@ -278,7 +292,7 @@ func TestBuildCodes(t *testing.T) {
 			t.Fatalf("expected 1 code, got %d", len(codes))
 		}
 
-		if code, ok := codes['a']; !ok || code != "" {
+		if code, ok := codes['a']; !ok || code.String() != "" {
 			t.Fatalf("single leaf must have empty code, got %q, %v", code, codes)
 		}
 	})
@@ -293,10 +307,10 @@ func TestBuildCodes(t *testing.T) {
 		if len(codes) != 2 {
 			t.Fatalf("invalid codes map size, expected: %d, got: %d", 1, len(codes))
 		}
-		if code, ok := codes['a']; !ok || code != "1" {
+		if code, ok := codes['a']; !ok || code.String() != "1" {
 			t.Fatalf("invalide code map builded, expected: %s, got: %s", "1", code)
 		}
-		if code, ok := codes['b']; !ok || code != "0" {
+		if code, ok := codes['b']; !ok || code.String() != "0" {
 			t.Fatalf("invalide code map builded, expected: %s, got: %s", "0", code)
 		}
 	})
@@ -335,7 +349,7 @@ func TestBuildCodes(t *testing.T) {
 		}
 
 		for char, code := range expected {
-			if actual, ok := codes[char]; !ok || actual != code {
+			if actual, ok := codes[char]; !ok || actual.String() != code {
 				t.Fatalf("invalud code builded, expected: %s, actual: %s", code, actual)
 			}
 		}
@@ -364,11 +378,11 @@ func FuzzBuldCodesPrefix(f *testing.F) {
 		codes := buildCodes(buildTree(freq))
 		for char1, code1 := range codes {
 			for char2, code2 := range codes {
-				if strings.HasPrefix(code1, code2) && char1 != char2 {
+				if strings.HasPrefix(code1.String(), code2.String()) && char1 != char2 {
 					t.Fatalf(
 						"prefix code invariant violated: char %q has code %q, char %q has code %q",
-						char1, code1,
-						char2, code2,
+						char1, code1.String(),
+						char2, code2.String(),
 					)
 				}
 			}
@@ -409,6 +423,29 @@ func BenchmarkBuildTree(b *testing.B) {
 			}
 		})
 	}
+
+	builders := []struct {
+		name  string
+		build func([]uint) *node
+	}{
+		{"heap", buildSymbolTree},
+		{"two_queue", buildTreeTwoQueue},
+		{"in_place", buildTreeInPlace},
+	}
+	for _, size := range []int{256, 4096, 65535} {
+		frequencies := make([]uint, size)
+		random := benchkit.Random(size * 2)
+		for i := range frequencies {
+			frequencies[i] = 1 + uint(random[2*i])<<8 | uint(random[2*i+1])
+		}
+		for _, builder := range builders {
+			b.Run(fmt.Sprintf("alphabet_%d/%s", size, builder.name), func(b *testing.B) {
+				for b.Loop() {
+					builder.build(frequencies)
+				}
+			})
+		}
+	}
 }
 
 func TestCalculateTreeSize(t *testing.T) {
@@ -457,7 +494,7 @@ func TestCalculateTreeSize(t *testing.T) {
 
 func TestCalculateContentSize(t *testing.T) {
 	t.Run("empty", func(t *testing.T) {
-		size, err := calculateContentSize(map[byte]string{}, map[byte]uint{})
+		size, err := calculateContentSize(map[byte]*bitio.BitStream{}, map[byte]uint{})
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -467,11 +504,11 @@ func TestCalculateContentSize(t *testing.T) {
 	})
 
 	t.Run("default", func(t *testing.T) {
-		codes := map[byte]string{
+		codes := codeStreams(map[byte]string{
 			'a': "1",
 			'b': "01",
 			'c': "00",
-		}
+		})
 		frequencies := map[byte]uint{
 			'a': 10,
 			'b': 5,
@@ -488,11 +525,11 @@ func TestCalculateContentSize(t *testing.T) {
 	})
 
 	t.Run("error handling", func(t *testing.T) {
-		codes := map[byte]string{
+		codes := codeStreams(map[byte]string{
 			'a': "1",
 			'b': "01",
 			'c': "00",
-		}
+		})
 		frequencies := map[byte]uint{
 			'a': 10,
 			'b': 5,
@@ -0,0 +1,17 @@
+// Package symbols formats bytes for the tree dumps of the huffman package and
+// the output of the command line tool.
+package symbols
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Format returns a printable byte quoted like a Go rune literal and any
+// other byte in hexadecimal.
+func Format(symbol byte) string {
+	if symbol >= 0x20 && symbol < 0x7f {
+		return strconv.QuoteRune(rune(symbol))
+	}
+	return fmt.Sprintf("0x%02x", symbol)
+}
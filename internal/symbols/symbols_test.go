@@ -0,0 +1,12 @@
+package symbols
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	testCases := map[byte]string{'a': "'a'", ' ': "' '", '\'': `'\''`, '\n': "0x0a", 0xff: "0xff"}
+	for symbol, expected := range testCases {
+		if result := Format(symbol); result != expected {
+			t.Fatalf("Format(%d): expected %q, got %q", symbol, expected, result)
+		}
+	}
+}
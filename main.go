@@ -1,30 +1,203 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strconv"
 
 	"github.com/serrhiy/go-huffman/huffman"
 )
 
 const OutputExtension = ".hfm"
+const DeflateExtension = ".deflate"
 
 var output = flag.String("o", "", "path to the output file")
 var encode = flag.String("e", "", "encode file")
 var decode = flag.String("d", "", "decode file")
+var noMetadata = flag.Bool("n", false, "do not save the original name, mode and modification time")
+var noName = flag.Bool("no-name", false, "do not save the original file name")
+var noMode = flag.Bool("no-mode", false, "do not save the original file mode")
+var noModTime = flag.Bool("no-mtime", false, "do not save the original modification time")
+var showProgress = flag.Bool("progress", false, "show progress on stderr")
+var verbose = flag.Bool("v", false, "print a summary when the operation completes")
+var force = flag.Bool("force", false, "overwrite the output file if it exists")
+var rawDeflate = flag.Bool("deflate", false, "write or read a raw DEFLATE stream instead of the .hfm format")
+var lz77 = flag.Bool("lz", false, "replace repeated strings with back references before Huffman coding")
+var window = flag.Int("window", 0, "maximum LZ77 match distance, a power of two up to 32768")
+var splitBlocks = flag.Bool("split", false, "start new blocks where the statistics of the input change, Huffman coding every block separately without -lz or a level")
+var levels = levelFlags()
+var dumpTree = flag.String("dump-tree", "", "print the Huffman tree (dot or json) instead of encoding or decoding")
 
-func encodeFile(in, out *os.File) error {
-	encoder := huffman.NewEncoder(in, out)
-	return encoder.Encode()
+func levelFlags() []*bool {
+	flags := make([]*bool, 10)
+	for level := 1; level < len(flags); level++ {
+		usage := fmt.Sprintf("compress with LZ77 at level %d (1 fastest, 9 smallest output)", level)
+		flags[level] = flag.Bool(strconv.Itoa(level), false, usage)
+	}
+	return flags
+}
+
+func getLevel() (int, error) {
+	result := 0
+	for level, set := range levels {
+		if set == nil || !*set {
+			continue
+		}
+		if result != 0 {
+			return 0, errors.New("specify only one compression level")
+		}
+		result = level
+	}
+	return result, nil
+}
+
+func outputExtension() string {
+	if *rawDeflate {
+		return DeflateExtension
+	}
+	return OutputExtension
+}
+
+func getHeader(in *os.File) (*huffman.Header, error) {
+	info, err := in.Stat()
+	if err != nil {
+		return nil, err
+	}
+	header := &huffman.Header{}
+	if !*noMetadata && !*noName {
+		header.Name = info.Name()
+	}
+	if !*noMetadata && !*noMode {
+		header.Mode = info.Mode()
+	}
+	if !*noMetadata && !*noModTime {
+		header.ModTime = info.ModTime()
+	}
+	return header, nil
+}
+
+func restoreHeader(out *os.File, header *huffman.Header) error {
+	if header == nil {
+		return nil
+	}
+	if header.Mode != 0 {
+		if err := out.Chmod(header.Mode.Perm()); err != nil {
+			return err
+		}
+	}
+	if !header.ModTime.IsZero() {
+		return os.Chtimes(out.Name(), header.ModTime, header.ModTime)
+	}
+	return nil
+}
+
+func getProgressBar(in *os.File) (*progressBar, error) {
+	if !*showProgress && !*verbose {
+		return nil, nil
+	}
+	info, err := in.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return newProgressBar(os.Stderr, info.Size(), *showProgress), nil
+}
+
+func finishProgress(bar *progressBar, operation string, err error) error {
+	if bar == nil {
+		return err
+	}
+	bar.finish()
+	if err == nil && *verbose {
+		fmt.Fprintln(os.Stderr, bar.summary(operation))
+	}
+	return err
+}
+
+func encodeFile(ctx context.Context, in, out *os.File) error {
+	header, err := getHeader(in)
+	if err != nil {
+		return err
+	}
+	bar, err := getProgressBar(in)
+	if err != nil {
+		return err
+	}
+	level, err := getLevel()
+	if err != nil {
+		return err
+	}
+	options := huffman.EncoderOptions{
+		Header:      header,
+		Level:       level,
+		SplitBlocks: *splitBlocks,
+		RawDeflate:  *rawDeflate,
+	}
+	if *lz77 {
+		options.LZ77 = &huffman.LZ77Options{Window: *window}
+	}
+	if bar != nil {
+		options.Progress = bar.update
+	}
+	encoder := huffman.NewEncoderWithOptions(in, out, options)
+	return finishProgress(bar, "encoded", encoder.EncodeContext(ctx))
 }
 
-func decodeFile(in, out *os.File) error {
-	decoder := huffman.NewDecoder(in, out)
-	return decoder.Decode()
+func decodeFile(ctx context.Context, in, out *os.File) error {
+	bar, err := getProgressBar(in)
+	if err != nil {
+		return err
+	}
+	options := huffman.DecoderOptions{RawDeflate: *rawDeflate}
+	if bar != nil {
+		options.Progress = bar.update
+	}
+	decoder := huffman.NewDecoderWithOptions(in, out, options)
+	if err := finishProgress(bar, "decoded", decoder.DecodeContext(ctx)); err != nil {
+		return err
+	}
+	return restoreHeader(out, decoder.Header())
+}
+
+// writeTree prints the tree the plain mode writes for the input, or the tree
+// stored in an encoded file. The other modes code blocks with tables of
+// their own, so there is no single tree to print.
+func writeTree(in io.Reader, fromInput bool, format string, out io.Writer) error {
+	if format != "dot" && format != "json" {
+		return fmt.Errorf("unknown tree format: %q", format)
+	}
+	level, err := getLevel()
+	if err != nil {
+		return err
+	}
+	if *lz77 || *rawDeflate || *splitBlocks || level > 0 {
+		return errors.New("-dump-tree applies only to the plain Huffman mode, not to -lz, -deflate, -split or a level")
+	}
+	var tree *huffman.Tree
+	if fromInput {
+		tree, err = huffman.BuildTree(in)
+	} else {
+		tree, err = huffman.ReadTree(in)
+	}
+	if err != nil {
+		return err
+	}
+	if format == "dot" {
+		return tree.WriteDot(out)
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(tree)
 }
 
-func start() error {
+// start runs the operation selected by the flags. Once ctx is done the
+// operation stops and the partial output is removed.
+func start(ctx context.Context) error {
 	arguments, err := getArguments(*encode, *decode, *output)
 	if err != nil {
 		return err
@@ -36,26 +209,74 @@ func start() error {
 	}
 	defer infile.Close()
 
-	outfile, err := os.OpenFile(arguments.outputFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if *dumpTree != "" {
+		return writeTree(infile, len(*encode) > 0, *dumpTree, os.Stdout)
+	}
+
+	if len(*decode) > 0 && arguments.outputFile == "" {
+		var header *huffman.Header
+		if !*rawDeflate {
+			header, err = huffman.ReadHeader(infile)
+			if err != nil {
+				return err
+			}
+			if _, err := infile.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		arguments.outputFile, err = getDecodeOutput(arguments.inputFile, header)
+		if err != nil {
+			return err
+		}
+	}
+
+	outfile, err := createOutput(arguments.outputFile, *force)
 	if err != nil {
-		return nil
+		return err
 	}
-	defer outfile.Close()
+	defer outfile.abort()
 
 	if len(*encode) > 0 {
-		return encodeFile(infile, outfile)
+		err = encodeFile(ctx, infile, outfile.File)
+	} else {
+		err = decodeFile(ctx, infile, outfile.File)
 	}
-	return decodeFile(infile, outfile)
+	if err != nil {
+		return err
+	}
+	return outfile.commit()
+}
+
+var commands = map[string]func([]string, io.Writer) error{
+	"bench":   runBench,
+	"analyze": runAnalyze,
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "Error occurred: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	flag.Parse()
 
-	if err := start(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	// the first Ctrl+C cancels ctx and restores the default behaviour, so a
+	// second one kills the process while the output is being cleaned up
+	context.AfterFunc(ctx, stop)
+	err := start(ctx)
+	stop()
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, "Interrupted, partial output removed")
+		os.Exit(130)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error occurred: %v\n", err)
-		if err == huffman.ErrInvalidStructure {
-			os.Remove(*output)
-		}
 		os.Exit(1)
 	}
 }
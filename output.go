@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const outputMode = 0644
+
+// link is os.Link, replaced by tests to emulate file systems without hard
+// links.
+var link = os.Link
+
+// outputFile is written to a temporary file next to the target path and
+// moved there only once commit is called, so the target never holds
+// partial output. Without force the file is linked to the target, which
+// fails instead of replacing a file created while the output was written.
+// Where the file system has no hard links, the target is created
+// exclusively first and then replaced by the temporary file.
+type outputFile struct {
+	*os.File
+	path  string
+	force bool
+	done  bool
+}
+
+func checkOutput(path string, force bool) error {
+	if force {
+		return nil
+	}
+	_, err := os.Lstat(path)
+	if err == nil {
+		return fmt.Errorf("output file %q already exists, use -force to overwrite it", path)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func createOutput(path string, force bool) (*outputFile, error) {
+	if err := checkOutput(path, force); err != nil {
+		return nil, err
+	}
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	file, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Chmod(outputMode); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return &outputFile{file, path, force, false}, nil
+}
+
+func (file *outputFile) commit() error {
+	if file.done {
+		return nil
+	}
+	file.done = true
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if file.force {
+		if err := os.Rename(file.Name(), file.path); err != nil {
+			os.Remove(file.Name())
+			return err
+		}
+		return nil
+	}
+	// linking fails if the target exists, unlike a check followed by a
+	// rename, so a file created in the meantime is never replaced
+	err := link(file.Name(), file.path)
+	if err != nil && !errors.Is(err, fs.ErrExist) {
+		err = file.claim()
+	}
+	os.Remove(file.Name())
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("output file %q already exists, use -force to overwrite it", file.path)
+	}
+	return err
+}
+
+// claim moves the temporary file to the target without hard links: the
+// target is created exclusively, which fails if it exists, and the empty
+// file is then replaced by the output.
+func (file *outputFile) claim() error {
+	target, err := os.OpenFile(file.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, outputMode)
+	if err != nil {
+		return err
+	}
+	target.Close()
+	if err := os.Rename(file.Name(), file.path); err != nil {
+		os.Remove(file.path)
+		return err
+	}
+	return nil
+}
+
+func (file *outputFile) abort() {
+	if file.done {
+		return
+	}
+	file.done = true
+	file.Close()
+	os.Remove(file.Name())
+}
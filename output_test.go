@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestCreateOutput(t *testing.T) {
+	t.Run("commit", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.hfm")
+		file, err := createOutput(path, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := os.Stat(path); err == nil {
+			t.Fatal("output must not exist before commit")
+		}
+		file.WriteString("data")
+		if err := file.commit(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil || string(content) != "data" {
+			t.Fatalf("invalid output, content: %q, err: %v", content, err)
+		}
+		if names := listDir(t, dir); len(names) != 1 {
+			t.Fatalf("temporary file left behind: %v", names)
+		}
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.hfm")
+		file, err := createOutput(path, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		file.WriteString("partial")
+		file.abort()
+		if names := listDir(t, dir); len(names) != 0 {
+			t.Fatalf("expected empty directory, got: %v", names)
+		}
+		if err := file.commit(); err != nil {
+			t.Fatalf("commit after abort must be a no-op, got: %v", err)
+		}
+	})
+
+	t.Run("existing output", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.hfm")
+		os.WriteFile(path, []byte("old"), 0644)
+		if _, err := createOutput(path, false); err == nil {
+			t.Fatal("expected error for existing output")
+		}
+		if names := listDir(t, dir); len(names) != 1 {
+			t.Fatalf("temporary file left behind: %v", names)
+		}
+	})
+
+	t.Run("output created concurrently", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.hfm")
+		file, err := createOutput(path, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		os.WriteFile(path, []byte("other"), 0644)
+		if err := file.commit(); err == nil {
+			t.Fatal("expected error for existing output")
+		}
+		content, _ := os.ReadFile(path)
+		if string(content) != "other" {
+			t.Fatalf("existing output was overwritten: %q", content)
+		}
+		if names := listDir(t, dir); len(names) != 1 {
+			t.Fatalf("temporary file left behind: %v", names)
+		}
+	})
+
+	t.Run("directory created concurrently", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.hfm")
+		file, err := createOutput(path, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		os.Mkdir(path, 0755)
+		if err := file.commit(); err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Fatalf("expected error for existing output, got: %v", err)
+		}
+		if names := listDir(t, dir); len(names) != 1 {
+			t.Fatalf("temporary file left behind: %v", names)
+		}
+	})
+
+	t.Run("no hard links", func(t *testing.T) {
+		link = func(oldname, newname string) error {
+			return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: errors.ErrUnsupported}
+		}
+		t.Cleanup(func() { link = os.Link })
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.hfm")
+		file, err := createOutput(path, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		file.WriteString("data")
+		if err := file.commit(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil || string(content) != "data" {
+			t.Fatalf("invalid output, content: %q, err: %v", content, err)
+		}
+		if names := listDir(t, dir); len(names) != 1 {
+			t.Fatalf("temporary file left behind: %v", names)
+		}
+
+		path = filepath.Join(dir, "other.hfm")
+		file, err = createOutput(path, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		os.WriteFile(path, []byte("other"), 0644)
+		if err := file.commit(); err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Fatalf("expected error for existing output, got: %v", err)
+		}
+		content, _ = os.ReadFile(path)
+		if string(content) != "other" {
+			t.Fatalf("existing output was overwritten: %q", content)
+		}
+		if names := listDir(t, dir); len(names) != 2 {
+			t.Fatalf("temporary file left behind: %v", names)
+		}
+	})
+
+	t.Run("force", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.hfm")
+		os.WriteFile(path, []byte("old"), 0644)
+		file, err := createOutput(path, true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		file.WriteString("new")
+		if err := file.commit(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		content, _ := os.ReadFile(path)
+		if string(content) != "new" {
+			t.Fatalf("expected output to be overwritten, got: %q", content)
+		}
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing", "out.hfm")
+		if _, err := createOutput(path, false); err == nil {
+			t.Fatal("expected error for missing directory")
+		}
+	})
+
+	t.Run("rename failure", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.hfm")
+		os.Mkdir(path, 0755)
+		os.WriteFile(filepath.Join(path, "child"), nil, 0644)
+		file, err := createOutput(path, true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := file.commit(); err == nil {
+			t.Fatal("expected error when renaming over a directory")
+		}
+		if names := listDir(t, dir); len(names) != 1 {
+			t.Fatalf("temporary file left behind: %v", names)
+		}
+	})
+}
+
+func withFlags(t *testing.T, encodePath, decodePath, outputPath string, overwrite bool) {
+	t.Helper()
+	previous := []string{*encode, *decode, *output}
+	previousForce := *force
+	*encode, *decode, *output, *force = encodePath, decodePath, outputPath, overwrite
+	t.Cleanup(func() {
+		*encode, *decode, *output = previous[0], previous[1], previous[2]
+		*force = previousForce
+	})
+}
+
+func TestStart(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		dir := t.TempDir()
+		input := filepath.Join(dir, "input.txt")
+		os.WriteFile(input, []byte("hello world"), 0644)
+		withFlags(t, input, "", "", false)
+		if err := start(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		os.Remove(input)
+		withFlags(t, "", filepath.Join(dir, "input.hfm"), "", false)
+		if err := start(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		content, err := os.ReadFile(input)
+		if err != nil || string(content) != "hello world" {
+			t.Fatalf("invalid decoded output, content: %q, err: %v", content, err)
+		}
+	})
+
+	t.Run("missing input", func(t *testing.T) {
+		dir := t.TempDir()
+		withFlags(t, filepath.Join(dir, "missing.txt"), "", "", false)
+		if err := start(context.Background()); err == nil {
+			t.Fatal("expected error for missing input")
+		}
+		if names := listDir(t, dir); len(names) != 0 {
+			t.Fatalf("expected empty directory, got: %v", names)
+		}
+	})
+
+	t.Run("unwritable output", func(t *testing.T) {
+		dir := t.TempDir()
+		input := filepath.Join(dir, "input.txt")
+		os.WriteFile(input, []byte("data"), 0644)
+		withFlags(t, input, "", filepath.Join(dir, "missing", "out.hfm"), false)
+		if err := start(context.Background()); err == nil {
+			t.Fatal("expected error for unwritable output")
+		}
+	})
+
+	t.Run("existing output", func(t *testing.T) {
+		dir := t.TempDir()
+		input := filepath.Join(dir, "input.txt")
+		os.WriteFile(input, []byte("data"), 0644)
+		os.WriteFile(filepath.Join(dir, "input.hfm"), []byte("old"), 0644)
+		withFlags(t, input, "", "", false)
+		if err := start(context.Background()); err == nil {
+			t.Fatal("expected error for existing output")
+		}
+		content, _ := os.ReadFile(filepath.Join(dir, "input.hfm"))
+		if string(content) != "old" {
+			t.Fatalf("existing output was modified: %q", content)
+		}
+		withFlags(t, input, "", "", true)
+		if err := start(context.Background()); err != nil {
+			t.Fatalf("unexpected error with -force: %v", err)
+		}
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		dir := t.TempDir()
+		input := filepath.Join(dir, "input.txt")
+		os.WriteFile(input, []byte("hello world"), 0644)
+		withFlags(t, input, "", "", false)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := start(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got: %v", err)
+		}
+		if names := listDir(t, dir); len(names) != 1 {
+			t.Fatalf("partial output left behind: %v", names)
+		}
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		dir := t.TempDir()
+		input := filepath.Join(dir, "garbage.hfm")
+		os.WriteFile(input, []byte{0xff, 0x00, 0xff}, 0644)
+		withFlags(t, "", input, filepath.Join(dir, "out.txt"), false)
+		if err := start(context.Background()); err == nil {
+			t.Fatal("expected error for invalid input")
+		}
+		if names := listDir(t, dir); len(names) != 1 {
+			t.Fatalf("partial output left behind: %v", names)
+		}
+	})
+
+	t.Run("raw deflate", func(t *testing.T) {
+		dir := t.TempDir()
+		input := filepath.Join(dir, "input.txt")
+		os.WriteFile(input, []byte("hello deflate"), 0644)
+		withFlags(t, input, "", "", false)
+		*rawDeflate = true
+		t.Cleanup(func() { *rawDeflate = false })
+		if err := start(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		encoded, err := os.ReadFile(filepath.Join(dir, "input.deflate"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		content, err := io.ReadAll(flate.NewReader(bytes.NewReader(encoded)))
+		if err != nil || string(content) != "hello deflate" {
+			t.Fatalf("invalid DEFLATE output, content: %q, err: %v", content, err)
+		}
+		os.Remove(input)
+		withFlags(t, "", filepath.Join(dir, "input.deflate"), "", false)
+		if err := start(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// raw DEFLATE keeps no file name, only the extension is stripped
+		content, err = os.ReadFile(filepath.Join(dir, "input"))
+		if err != nil || string(content) != "hello deflate" {
+			t.Fatalf("invalid decoded output, content: %q, err: %v", content, err)
+		}
+	})
+
+	t.Run("lz77", func(t *testing.T) {
+		dir := t.TempDir()
+		input := filepath.Join(dir, "app.log")
+		source := bytes.Repeat([]byte("GET /index.html 200\n"), 500)
+		os.WriteFile(input, source, 0644)
+		withFlags(t, input, "", "", false)
+		*lz77 = true
+		t.Cleanup(func() { *lz77 = false })
+		if err := start(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		info, err := os.Stat(filepath.Join(dir, "app.hfm"))
+		if err != nil || info.Size() > int64(len(source)/10) {
+			t.Fatalf("expected small LZ77 output, info: %v, err: %v", info, err)
+		}
+		os.Remove(input)
+		withFlags(t, "", filepath.Join(dir, "app.hfm"), "", false)
+		if err := start(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		content, err := os.ReadFile(input)
+		if err != nil || !bytes.Equal(content, source) {
+			t.Fatalf("invalid decoded output, err: %v", err)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/serrhiy/go-huffman/huffman"
+)
+
+const refreshInterval = 200 * time.Millisecond
+
+type progressBar struct {
+	out      io.Writer
+	total    int64
+	visible  bool
+	now      func() time.Time
+	started  time.Time
+	rendered time.Time
+	progress huffman.Progress
+}
+
+func newProgressBar(out io.Writer, total int64, visible bool) *progressBar {
+	now := time.Now
+	return &progressBar{out, total, visible, now, now(), time.Time{}, huffman.Progress{}}
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size) / unit
+	suffixes := []string{"KiB", "MiB", "GiB", "TiB"}
+	index := 0
+	for value >= unit && index < len(suffixes)-1 {
+		value /= unit
+		index += 1
+	}
+	return fmt.Sprintf("%.1f %s", value, suffixes[index])
+}
+
+func formatDuration(duration time.Duration) string {
+	seconds := int64(duration.Round(time.Second) / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+func throughput(size int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(size) / 1e6 / elapsed.Seconds()
+}
+
+func (bar *progressBar) status(now time.Time) string {
+	elapsed := now.Sub(bar.started)
+	// the plain mode reads the input twice, the first time to count bytes
+	read, total := bar.progress.Read, bar.total
+	if bar.progress.Counted > 0 {
+		read, total = read+bar.progress.Counted, 2*total
+	}
+	speed := throughput(read, elapsed)
+	if total <= 0 {
+		return fmt.Sprintf("%s  %.1f MB/s", formatBytes(read), speed)
+	}
+	percent := float64(read) / float64(total) * 100
+	eta := "-:--"
+	if read > 0 && read <= total {
+		remaining := time.Duration(float64(elapsed) * float64(total-read) / float64(read))
+		eta = formatDuration(remaining)
+	}
+	return fmt.Sprintf(
+		"%5.1f%%  %s / %s  %.1f MB/s  ETA %s",
+		percent, formatBytes(read), formatBytes(total), speed, eta,
+	)
+}
+
+func (bar *progressBar) update(progress huffman.Progress) {
+	bar.progress = progress
+	if !bar.visible {
+		return
+	}
+	now := bar.now()
+	if now.Sub(bar.rendered) < refreshInterval {
+		return
+	}
+	bar.rendered = now
+	fmt.Fprintf(bar.out, "\r%s\033[K", bar.status(now))
+}
+
+func (bar *progressBar) finish() {
+	if bar.visible {
+		fmt.Fprintf(bar.out, "\r%s\033[K\n", bar.status(bar.now()))
+	}
+}
+
+func (bar *progressBar) summary(operation string) string {
+	elapsed := bar.now().Sub(bar.started)
+	read, written := bar.progress.Read, bar.progress.Written
+	ratio := 0.0
+	if read > 0 {
+		ratio = float64(written) / float64(read) * 100
+	}
+	return fmt.Sprintf(
+		"%s %s -> %s (%.2f%%) in %s, %.1f MB/s",
+		operation, formatBytes(read), formatBytes(written), ratio,
+		elapsed.Round(time.Millisecond), throughput(read, elapsed),
+	)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/serrhiy/go-huffman/huffman"
+)
+
+func TestFormatBytes(t *testing.T) {
+	testCases := []struct {
+		size     int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+		{3 << 30, "3.0 GiB"},
+		{2 << 50, "2048.0 TiB"},
+	}
+	for _, tc := range testCases {
+		if result := formatBytes(tc.size); result != tc.expected {
+			t.Fatalf("formatBytes(%d): expected %q, got %q", tc.size, tc.expected, result)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	testCases := []struct {
+		duration time.Duration
+		expected string
+	}{
+		{0, "0:00"},
+		{4 * time.Second, "0:04"},
+		{61 * time.Second, "1:01"},
+		{time.Hour + 2*time.Minute + 3*time.Second, "1:02:03"},
+	}
+	for _, tc := range testCases {
+		if result := formatDuration(tc.duration); result != tc.expected {
+			t.Fatalf("formatDuration(%v): expected %q, got %q", tc.duration, tc.expected, result)
+		}
+	}
+}
+
+func newTestProgressBar(total int64, visible bool) (*progressBar, *bytes.Buffer, *time.Time) {
+	out := &bytes.Buffer{}
+	bar := newProgressBar(out, total, visible)
+	now := time.Unix(1000, 0)
+	bar.started = now
+	bar.now = func() time.Time { return now }
+	return bar, out, &now
+}
+
+func TestProgressBar(t *testing.T) {
+	t.Run("status", func(t *testing.T) {
+		bar, _, now := newTestProgressBar(4_000_000, true)
+		*now = now.Add(time.Second)
+		bar.progress = huffman.Progress{Read: 1_000_000}
+		status := bar.status(*now)
+		expected := " 25.0%  976.6 KiB / 3.8 MiB  1.0 MB/s  ETA 0:03"
+		if status != expected {
+			t.Fatalf("expected %q, got %q", expected, status)
+		}
+	})
+
+	t.Run("two passes", func(t *testing.T) {
+		bar, _, now := newTestProgressBar(2_000_000, true)
+		*now = now.Add(time.Second)
+		bar.progress = huffman.Progress{Counted: 1_000_000}
+		expected := " 25.0%  976.6 KiB / 3.8 MiB  1.0 MB/s  ETA 0:03"
+		if status := bar.status(*now); status != expected {
+			t.Fatalf("expected %q, got %q", expected, status)
+		}
+		bar.progress = huffman.Progress{Read: 1_000_000, Counted: 2_000_000}
+		expected = " 75.0%  2.9 MiB / 3.8 MiB  3.0 MB/s  ETA 0:00"
+		if status := bar.status(*now); status != expected {
+			t.Fatalf("expected %q, got %q", expected, status)
+		}
+	})
+
+	t.Run("unknown total", func(t *testing.T) {
+		bar, _, now := newTestProgressBar(0, true)
+		*now = now.Add(2 * time.Second)
+		bar.progress = huffman.Progress{Read: 4_000_000}
+		if status := bar.status(*now); status != "3.8 MiB  2.0 MB/s" {
+			t.Fatalf("unexpected status: %q", status)
+		}
+	})
+
+	t.Run("throttling", func(t *testing.T) {
+		bar, out, now := newTestProgressBar(100, true)
+		*now = now.Add(time.Second)
+		bar.update(huffman.Progress{Read: 10})
+		bar.update(huffman.Progress{Read: 20})
+		if count := strings.Count(out.String(), "\r"); count != 1 {
+			t.Fatalf("expected 1 rendered line, got: %d", count)
+		}
+		*now = now.Add(refreshInterval)
+		bar.update(huffman.Progress{Read: 30})
+		if count := strings.Count(out.String(), "\r"); count != 2 {
+			t.Fatalf("expected 2 rendered lines, got: %d", count)
+		}
+		bar.finish()
+		if !strings.HasSuffix(out.String(), "\n") {
+			t.Fatalf("finish must end the status line: %q", out.String())
+		}
+	})
+
+	t.Run("hidden", func(t *testing.T) {
+		bar, out, now := newTestProgressBar(100, false)
+		*now = now.Add(time.Second)
+		bar.update(huffman.Progress{Read: 100, Written: 50})
+		bar.finish()
+		if out.Len() != 0 {
+			t.Fatalf("hidden progress bar must not print, got: %q", out.String())
+		}
+		if bar.progress.Written != 50 {
+			t.Fatalf("hidden progress bar must track progress, got: %+v", bar.progress)
+		}
+	})
+
+	t.Run("summary", func(t *testing.T) {
+		bar, _, now := newTestProgressBar(2_000_000, false)
+		*now = now.Add(2 * time.Second)
+		bar.update(huffman.Progress{Read: 2_000_000, Written: 500_000})
+		expected := "encoded 1.9 MiB -> 488.3 KiB (25.00%) in 2s, 1.0 MB/s"
+		if summary := bar.summary("encoded"); summary != expected {
+			t.Fatalf("expected %q, got %q", expected, summary)
+		}
+	})
+}